@@ -0,0 +1,385 @@
+package aot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"sort"
+)
+
+//go:generate go run ../cmd/chip8aot -rom "../tests/Chip8 Picture.ch8" -package picture -out internal/picture/picture.go
+
+const (
+	// defaultStart is the address programs are loaded to when a start has not been set
+	defaultStart = 0x200
+	// maxBlockLength is the maximum number of instructions in a block
+	// Blocks are kept short so that they fit within the instructions executed per frame
+	maxBlockLength = 8
+)
+
+var (
+	// ErrEmptyProgram is returned when an empty program is translated
+	ErrEmptyProgram = errors.New("cannot translate, program is empty")
+	// ErrInvalidPackage is returned when a package name has not been provided
+	ErrInvalidPackage = errors.New("invalid package name, cannot be empty")
+)
+
+// Options are the options used to translate a program
+type Options struct {
+	// Package is the name of the generated package
+	Package string
+	// Source is the name of the program file, it is included in the generated header
+	Source string
+	// Start is the address the program is loaded to, 0x200 is used when zero
+	Start uint16
+}
+
+// Translate will statically disassemble a program by following its control flow from the start address
+// and return the source of a Go package which implements vm.Native for it
+// Indirect jumps (BNNN) and code which is only reached through them are left to the interpreter
+func Translate(program []byte, opts Options) (src []byte, err error) {
+	if len(program) == 0 {
+		return nil, ErrEmptyProgram
+	}
+
+	if opts.Package == "" {
+		return nil, ErrInvalidPackage
+	}
+
+	if opts.Start == 0 {
+		opts.Start = defaultStart
+	}
+
+	t := newTranslator(program, opts.Start)
+	t.discover()
+
+	var buf bytes.Buffer
+	t.write(&buf, opts)
+	return format.Source(buf.Bytes())
+}
+
+func newTranslator(program []byte, start uint16) *translator {
+	var t translator
+	t.program = program
+	t.start = start
+	t.reachable = make(map[uint16]bool)
+	t.leaders = make(map[uint16]bool)
+	return &t
+}
+
+// translator discovers the reachable instructions of a program and writes them as Go blocks
+type translator struct {
+	program []byte
+	start   uint16
+
+	// Addresses of the instructions reachable from the start
+	reachable map[uint16]bool
+	// Addresses which are the target of control flow, each starts a block
+	leaders map[uint16]bool
+}
+
+// opcodeAt will return the opcode at the provided address, ok is false when the address is outside of the program
+func (t *translator) opcodeAt(address uint16) (o uint16, ok bool) {
+	if address < t.start {
+		return
+	}
+
+	offset := int(address - t.start)
+	if offset+1 >= len(t.program) {
+		return
+	}
+
+	return uint16(t.program[offset])<<8 | uint16(t.program[offset+1]), true
+}
+
+// discover will follow the control flow of the program from the start address
+func (t *translator) discover() {
+	queue := []uint16{t.start}
+	t.leaders[t.start] = true
+	for len(queue) > 0 {
+		address := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if t.reachable[address] {
+			// Instruction has already been visited, continue
+			continue
+		}
+
+		o, ok := t.opcodeAt(address)
+		if !ok {
+			// Address is outside of the program, leave it to the interpreter
+			continue
+		}
+
+		t.reachable[address] = true
+		next, sequential := t.successors(address, o)
+		for _, n := range next {
+			if !sequential {
+				// Control flow target, starts a block
+				t.leaders[n] = true
+			}
+
+			queue = append(queue, n)
+		}
+	}
+}
+
+// successors will return the addresses which can be executed after the instruction at address
+// sequential is true when the instruction always continues with the following instruction and can be part of a block
+func (t *translator) successors(address, o uint16) (next []uint16, sequential bool) {
+	n := o & 0x000F
+	nn := o & 0x00FF
+	nnn := o & 0x0FFF
+	following := address + 2
+
+	switch o & 0xF000 {
+	case 0x0000:
+		switch {
+		case o == 0x00EE, o == 0x00FD:
+			// Return and exit do not continue
+			return
+		case o == 0x00E0, o&0xFFF0 == 0x00C0, o >= 0x00FB && o <= 0x00FF:
+			// Clearing, scrolling and resolution changes continue with the next instruction
+			return []uint16{following}, true
+		}
+
+		// Machine code calls and MegaChip opcodes are left to the interpreter
+		return
+
+	case 0x1000:
+		return []uint16{nnn}, false
+	case 0x2000:
+		return []uint16{nnn, following}, false
+	case 0x3000, 0x4000:
+		return []uint16{following, t.skipTarget(address)}, false
+	case 0xE000:
+		if nn == 0x9E || nn == 0xA1 {
+			return []uint16{following, t.skipTarget(address)}, false
+		}
+
+		// Unknown opcode, most likely data
+		return
+
+	case 0x5000, 0x9000:
+		if n == 0 {
+			return []uint16{following, t.skipTarget(address)}, false
+		}
+
+		// Register range save and load
+		return []uint16{following}, false
+
+	case 0x6000, 0x7000, 0xA000, 0xC000:
+		return []uint16{following}, true
+	case 0x8000:
+		return []uint16{following}, n <= 0x7 || n == 0xE
+	case 0xB000:
+		// Indirect jump, left to the interpreter
+		return
+	case 0xD000:
+		// Drawing may wait for the next frame
+		return []uint16{following}, false
+	}
+
+	switch {
+	case o == 0xF000:
+		// Long index load is four bytes long
+		return []uint16{address + 4}, false
+	case o == 0xF002, nn == 0x0A, nn == 0x33, nn == 0x55:
+		// Audio pattern load, key wait and memory writes end blocks
+		return []uint16{following}, false
+	}
+
+	switch nn {
+	case 0x01, 0x07, 0x15, 0x18, 0x1E, 0x29, 0x30, 0x3A, 0x65, 0x75, 0x85:
+		return []uint16{following}, true
+	}
+
+	// Unknown opcode, most likely data
+	return
+}
+
+// skipTarget will return the address executed when the skip instruction at address skips
+func (t *translator) skipTarget(address uint16) uint16 {
+	if o, _ := t.opcodeAt(address + 2); o == 0xF000 {
+		// Long index load is four bytes long
+		return address + 6
+	}
+
+	return address + 4
+}
+
+// block is a run of instructions which is translated into a single Go function
+type block struct {
+	start uint16
+	// Addresses and opcodes of the instructions in the block
+	addresses []uint16
+	opcodes   []uint16
+	// Number of bytes the block was translated from
+	size uint16
+}
+
+// blocks will return the blocks of the program, sorted by start address
+func (t *translator) blocks() (bs []block) {
+	starts := make([]uint16, 0, len(t.leaders))
+	for address := range t.leaders {
+		if t.reachable[address] {
+			starts = append(starts, address)
+		}
+	}
+
+	for len(starts) > 0 {
+		b := t.block(starts[0])
+		starts = starts[1:]
+		bs = append(bs, b)
+
+		end := b.start + uint16(len(b.opcodes))*2
+		if len(b.opcodes) == maxBlockLength && t.reachable[end] && !t.leaders[end] {
+			// Block was split at the maximum length, the rest of it starts a new block
+			t.leaders[end] = true
+			starts = append(starts, end)
+		}
+	}
+
+	sort.Slice(bs, func(i, j int) bool { return bs[i].start < bs[j].start })
+	return
+}
+
+// block will return the block starting at the provided address
+func (t *translator) block(start uint16) (b block) {
+	b.start = start
+	address := start
+	for len(b.opcodes) < maxBlockLength {
+		o, _ := t.opcodeAt(address)
+		b.addresses = append(b.addresses, address)
+		b.opcodes = append(b.opcodes, o)
+
+		_, sequential := t.successors(address, o)
+		address += 2
+		if !sequential || t.leaders[address] || !t.reachable[address] {
+			// Block ends at control flow or at the start of another block
+			break
+		}
+	}
+
+	b.size = address - start
+	if isSkip(b.opcodes[len(b.opcodes)-1]) {
+		// Skip targets depend on the following instruction, include it so that modifying it is noticed
+		b.size += 2
+	}
+
+	return
+}
+
+// write will write the Go source of the program to the buffer
+func (t *translator) write(buf *bytes.Buffer, opts Options) {
+	source := opts.Source
+	if source == "" {
+		source = "a CHIP-8 program"
+	}
+
+	fmt.Fprintf(buf, "// Code generated by chip8aot from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(buf, "// Package %s is %s translated into Go, it is run with vm.VM.SetNative(%s.Program)\n", opts.Package, source, opts.Package)
+	fmt.Fprintf(buf, "package %s\n\n", opts.Package)
+	fmt.Fprintf(buf, "import \"github.com/itsmontoya/chip8/vm\"\n\n")
+	fmt.Fprintf(buf, "// Program is the translated program\nvar Program program\n\n")
+	fmt.Fprintf(buf, "type program struct{}\n\n")
+
+	bs := t.blocks()
+	fmt.Fprintf(buf, "// Block will return the translated block starting at the provided address\n")
+	fmt.Fprintf(buf, "func (program) Block(address uint16) (b vm.NativeBlock, ok bool) {\n")
+	fmt.Fprintf(buf, "switch address {\n")
+	for _, b := range bs {
+		fmt.Fprintf(buf, "case 0x%03X:\n", b.start)
+		fmt.Fprintf(buf, "return vm.NativeBlock{Run: block%03X, Size: %d, Instructions: %d}, true\n", b.start, b.size, len(b.opcodes))
+	}
+
+	fmt.Fprintf(buf, "}\n\nreturn\n}\n")
+	for _, b := range bs {
+		t.writeBlock(buf, b)
+	}
+}
+
+// writeBlock will write the Go function for a block to the buffer
+func (t *translator) writeBlock(buf *bytes.Buffer, b block) {
+	fmt.Fprintf(buf, "\nfunc block%03X(v *vm.VM) (err error) {\n", b.start)
+	for i, o := range b.opcodes {
+		address := b.addresses[i]
+		fmt.Fprintf(buf, "// 0x%03X: %04X\n", address, o)
+		if t.writeInline(buf, address, o) {
+			continue
+		}
+
+		// Execute the instruction through the interpreter at its address
+		fmt.Fprintf(buf, "v.SetProgramCounter(0x%03X)\n", address)
+		if i == len(b.opcodes)-1 {
+			// Interpreter leaves the program counter at the next instruction
+			fmt.Fprintf(buf, "return v.Execute(0x%04X)\n}\n", o)
+			return
+		}
+
+		fmt.Fprintf(buf, "if err = v.Execute(0x%04X); err != nil {\nreturn\n}\n", o)
+	}
+
+	last := b.addresses[len(b.addresses)-1]
+	if _, sequential := t.successors(last, b.opcodes[len(b.opcodes)-1]); sequential {
+		// Inline instructions do not move the program counter, continue with the next block
+		fmt.Fprintf(buf, "v.SetProgramCounter(0x%03X)\n", last+2)
+	}
+
+	fmt.Fprintf(buf, "return\n}\n")
+}
+
+// writeInline will write an instruction as Go, ok is false when it has to be executed by the interpreter
+func (t *translator) writeInline(buf *bytes.Buffer, address, o uint16) (ok bool) {
+	x := o & 0x0F00 >> 8
+	y := o & 0x00F0 >> 4
+	nn := o & 0x00FF
+	nnn := o & 0x0FFF
+
+	switch {
+	case o&0xF000 == 0x1000:
+		fmt.Fprintf(buf, "v.SetProgramCounter(0x%03X)\n", nnn)
+	case o&0xF000 == 0x3000:
+		t.writeSkip(buf, address, fmt.Sprintf("v.Register(0x%X) == 0x%02X", x, nn))
+	case o&0xF000 == 0x4000:
+		t.writeSkip(buf, address, fmt.Sprintf("v.Register(0x%X) != 0x%02X", x, nn))
+	case o&0xF00F == 0x5000:
+		t.writeSkip(buf, address, fmt.Sprintf("v.Register(0x%X) == v.Register(0x%X)", x, y))
+	case o&0xF00F == 0x9000:
+		t.writeSkip(buf, address, fmt.Sprintf("v.Register(0x%X) != v.Register(0x%X)", x, y))
+	case o&0xF000 == 0x6000:
+		fmt.Fprintf(buf, "v.SetRegister(0x%X, 0x%02X)\n", x, nn)
+	case o&0xF000 == 0x7000:
+		fmt.Fprintf(buf, "v.SetRegister(0x%X, v.Register(0x%X)+0x%02X)\n", x, x, nn)
+	case o&0xF00F == 0x8000:
+		fmt.Fprintf(buf, "v.SetRegister(0x%X, v.Register(0x%X))\n", x, y)
+	case o&0xF000 == 0xA000:
+		fmt.Fprintf(buf, "v.SetIndex(0x%03X)\n", nnn)
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+// writeSkip will write a skip instruction which skips the following instruction when the condition is true
+func (t *translator) writeSkip(buf *bytes.Buffer, address uint16, condition string) {
+	fmt.Fprintf(buf, "if %s {\n", condition)
+	fmt.Fprintf(buf, "v.SetProgramCounter(0x%03X)\n", t.skipTarget(address))
+	fmt.Fprintf(buf, "} else {\n")
+	fmt.Fprintf(buf, "v.SetProgramCounter(0x%03X)\n", address+2)
+	fmt.Fprintf(buf, "}\n")
+}
+
+// isSkip will return whether an opcode conditionally skips the following instruction
+func isSkip(o uint16) bool {
+	switch o & 0xF000 {
+	case 0x3000, 0x4000, 0xE000:
+		return true
+	case 0x5000, 0x9000:
+		return o&0x000F == 0
+	}
+
+	return false
+}
@@ -0,0 +1,114 @@
+package aot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/itsmontoya/chip8/aot/internal/picture"
+	"github.com/itsmontoya/chip8/vm"
+)
+
+const testProgram = "../tests/Chip8 Picture.ch8"
+
+func TestTranslator_blocks(t *testing.T) {
+	program := []byte{
+		// 0x200: Set V0, call 0x20A
+		0x60, 0x01, 0x22, 0x0A,
+		// 0x204: Skip when V0 is 1, then jump to 0x200 or indirectly jump
+		0x30, 0x01, 0x12, 0x00, 0xB2, 0x00,
+		// 0x20A: Add to V0 and return
+		0x70, 0x01, 0x00, 0xEE,
+		// 0x20E: Unreachable
+		0x60, 0x02,
+	}
+
+	tr := newTranslator(program, 0x200)
+	tr.discover()
+
+	expected := []uint16{0x200, 0x204, 0x206, 0x208, 0x20A}
+	bs := tr.blocks()
+	if len(bs) != len(expected) {
+		t.Fatalf("invalid number of blocks, expected %d and received %d", len(expected), len(bs))
+	}
+
+	for i, b := range bs {
+		if b.start != expected[i] {
+			t.Fatalf("invalid block start, expected 0x%03X and received 0x%03X", expected[i], b.start)
+		}
+	}
+
+	if tr.reachable[0x20E] {
+		t.Fatal("unreachable instruction was discovered")
+	}
+
+	// Skip blocks include the instruction which may be skipped
+	if bs[1].size != 4 {
+		t.Fatalf("invalid block size, expected 4 and received %d", bs[1].size)
+	}
+}
+
+func TestTranslate_generated(t *testing.T) {
+	program, err := ioutil.ReadFile(testProgram)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opts Options
+	opts.Package = "picture"
+	opts.Source = "Chip8 Picture.ch8"
+
+	src, err := Translate(program, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := ioutil.ReadFile("internal/picture/picture.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(src, generated) {
+		t.Fatal("generated program is out of date, run go generate")
+	}
+}
+
+func TestProgram_matchesInterpreter(t *testing.T) {
+	interpreted := run(t, nil)
+	native := run(t, picture.Program)
+
+	if interpreted.ProgramCounter() != native.ProgramCounter() {
+		t.Fatalf("invalid program counter, expected 0x%03X and received 0x%03X", interpreted.ProgramCounter(), native.ProgramCounter())
+	}
+
+	for x := byte(0); x < 16; x++ {
+		if interpreted.Register(x) != native.Register(x) {
+			t.Fatalf("invalid V%X, expected %d and received %d", x, interpreted.Register(x), native.Register(x))
+		}
+	}
+
+	ig := interpreted.Graphics()
+	ng := native.Graphics()
+	for i := 0; i < ig.Len(); i++ {
+		if ig.Get(i) != ng.Get(i) {
+			t.Fatalf("invalid pixel at %d, expected %d and received %d", i, ig.Get(i), ng.Get(i))
+		}
+	}
+}
+
+func run(t *testing.T, n vm.Native) (v *vm.VM) {
+	v = &vm.VM{}
+	if err := v.Load(testProgram); err != nil {
+		t.Fatal(err)
+	}
+
+	v.Initialize(nil)
+	v.SetNative(n)
+	for i := 0; i < 30; i++ {
+		if _, err := v.Frame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return
+}
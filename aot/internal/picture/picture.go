@@ -0,0 +1,189 @@
+// Code generated by chip8aot from Chip8 Picture.ch8. DO NOT EDIT.
+
+// Package picture is Chip8 Picture.ch8 translated into Go, it is run with vm.VM.SetNative(picture.Program)
+package picture
+
+import "github.com/itsmontoya/chip8/vm"
+
+// Program is the translated program
+var Program program
+
+type program struct{}
+
+// Block will return the translated block starting at the provided address
+func (program) Block(address uint16) (b vm.NativeBlock, ok bool) {
+	switch address {
+	case 0x200:
+		return vm.NativeBlock{Run: block200, Size: 10, Instructions: 5}, true
+	case 0x20A:
+		return vm.NativeBlock{Run: block20A, Size: 2, Instructions: 1}, true
+	case 0x20C:
+		return vm.NativeBlock{Run: block20C, Size: 2, Instructions: 1}, true
+	case 0x20E:
+		return vm.NativeBlock{Run: block20E, Size: 6, Instructions: 2}, true
+	case 0x212:
+		return vm.NativeBlock{Run: block212, Size: 2, Instructions: 1}, true
+	case 0x214:
+		return vm.NativeBlock{Run: block214, Size: 10, Instructions: 5}, true
+	case 0x21E:
+		return vm.NativeBlock{Run: block21E, Size: 2, Instructions: 1}, true
+	case 0x220:
+		return vm.NativeBlock{Run: block220, Size: 4, Instructions: 2}, true
+	case 0x224:
+		return vm.NativeBlock{Run: block224, Size: 2, Instructions: 1}, true
+	case 0x226:
+		return vm.NativeBlock{Run: block226, Size: 8, Instructions: 4}, true
+	case 0x22E:
+		return vm.NativeBlock{Run: block22E, Size: 6, Instructions: 3}, true
+	case 0x234:
+		return vm.NativeBlock{Run: block234, Size: 6, Instructions: 3}, true
+	case 0x23A:
+		return vm.NativeBlock{Run: block23A, Size: 6, Instructions: 3}, true
+	case 0x240:
+		return vm.NativeBlock{Run: block240, Size: 6, Instructions: 3}, true
+	case 0x246:
+		return vm.NativeBlock{Run: block246, Size: 2, Instructions: 1}, true
+	}
+
+	return
+}
+
+func block200(v *vm.VM) (err error) {
+	// 0x200: 00E0
+	v.SetProgramCounter(0x200)
+	if err = v.Execute(0x00E0); err != nil {
+		return
+	}
+	// 0x202: A248
+	v.SetIndex(0x248)
+	// 0x204: 6000
+	v.SetRegister(0x0, 0x00)
+	// 0x206: 611E
+	v.SetRegister(0x1, 0x1E)
+	// 0x208: 6200
+	v.SetRegister(0x2, 0x00)
+	v.SetProgramCounter(0x20A)
+	return
+}
+
+func block20A(v *vm.VM) (err error) {
+	// 0x20A: D202
+	v.SetProgramCounter(0x20A)
+	return v.Execute(0xD202)
+}
+
+func block20C(v *vm.VM) (err error) {
+	// 0x20C: D212
+	v.SetProgramCounter(0x20C)
+	return v.Execute(0xD212)
+}
+
+func block20E(v *vm.VM) (err error) {
+	// 0x20E: 7208
+	v.SetRegister(0x2, v.Register(0x2)+0x08)
+	// 0x210: 3240
+	if v.Register(0x2) == 0x40 {
+		v.SetProgramCounter(0x214)
+	} else {
+		v.SetProgramCounter(0x212)
+	}
+	return
+}
+
+func block212(v *vm.VM) (err error) {
+	// 0x212: 120A
+	v.SetProgramCounter(0x20A)
+	return
+}
+
+func block214(v *vm.VM) (err error) {
+	// 0x214: 6000
+	v.SetRegister(0x0, 0x00)
+	// 0x216: 613E
+	v.SetRegister(0x1, 0x3E)
+	// 0x218: 6202
+	v.SetRegister(0x2, 0x02)
+	// 0x21A: A24A
+	v.SetIndex(0x24A)
+	// 0x21C: D02E
+	v.SetProgramCounter(0x21C)
+	return v.Execute(0xD02E)
+}
+
+func block21E(v *vm.VM) (err error) {
+	// 0x21E: D12E
+	v.SetProgramCounter(0x21E)
+	return v.Execute(0xD12E)
+}
+
+func block220(v *vm.VM) (err error) {
+	// 0x220: 720E
+	v.SetRegister(0x2, v.Register(0x2)+0x0E)
+	// 0x222: D02E
+	v.SetProgramCounter(0x222)
+	return v.Execute(0xD02E)
+}
+
+func block224(v *vm.VM) (err error) {
+	// 0x224: D12E
+	v.SetProgramCounter(0x224)
+	return v.Execute(0xD12E)
+}
+
+func block226(v *vm.VM) (err error) {
+	// 0x226: A258
+	v.SetIndex(0x258)
+	// 0x228: 600B
+	v.SetRegister(0x0, 0x0B)
+	// 0x22A: 6108
+	v.SetRegister(0x1, 0x08)
+	// 0x22C: D01F
+	v.SetProgramCounter(0x22C)
+	return v.Execute(0xD01F)
+}
+
+func block22E(v *vm.VM) (err error) {
+	// 0x22E: 700A
+	v.SetRegister(0x0, v.Register(0x0)+0x0A)
+	// 0x230: A267
+	v.SetIndex(0x267)
+	// 0x232: D01F
+	v.SetProgramCounter(0x232)
+	return v.Execute(0xD01F)
+}
+
+func block234(v *vm.VM) (err error) {
+	// 0x234: 700A
+	v.SetRegister(0x0, v.Register(0x0)+0x0A)
+	// 0x236: A276
+	v.SetIndex(0x276)
+	// 0x238: D01F
+	v.SetProgramCounter(0x238)
+	return v.Execute(0xD01F)
+}
+
+func block23A(v *vm.VM) (err error) {
+	// 0x23A: 7003
+	v.SetRegister(0x0, v.Register(0x0)+0x03)
+	// 0x23C: A285
+	v.SetIndex(0x285)
+	// 0x23E: D01F
+	v.SetProgramCounter(0x23E)
+	return v.Execute(0xD01F)
+}
+
+func block240(v *vm.VM) (err error) {
+	// 0x240: 700A
+	v.SetRegister(0x0, v.Register(0x0)+0x0A)
+	// 0x242: A294
+	v.SetIndex(0x294)
+	// 0x244: D01F
+	v.SetProgramCounter(0x244)
+	return v.Execute(0xD01F)
+}
+
+func block246(v *vm.VM) (err error) {
+	// 0x246: 1246
+	v.SetProgramCounter(0x246)
+	return
+}
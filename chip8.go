@@ -2,16 +2,22 @@ package main
 
 import (
 	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
 
+	"github.com/itsmontoya/chip8/cosmac"
 	"github.com/itsmontoya/chip8/vm"
 )
 
 // New will return a new instance of Chip8
-func New(screenMultiplier float64) *Chip8 {
+func New(opts Options) *Chip8 {
 	var c Chip8
 	c.ctx, c.cancel = context.WithCancel(context.Background())
-	c.screenMultiplier = screenMultiplier
+	c.opts = opts
 	c.errC = make(chan error, 2)
+	c.wg.Add(1)
 	return &c
 }
 
@@ -20,34 +26,214 @@ type Chip8 struct {
 	ctx    context.Context
 	cancel func()
 
-	screenMultiplier float64
+	opts Options
 
 	errC chan error
+	// Done once run has returned
+	wg sync.WaitGroup
+
+	// Buzzer output, set when a WAV file has been provided
+	wav *vm.WAVAudio
 }
 
 func (c *Chip8) run() {
 	var (
-		vm  vm.VM
-		p   *PixelRenderer
-		err error
+		m     machine
+		r     vm.Renderer
+		input *vm.InputRecorder
+		err   error
 	)
+	defer c.wg.Done()
+
+	if m, err = c.newMachine(); err != nil {
+		// Error encountered while creating machine, return
+		c.errC <- err
+		return
+	}
 
-	if err = vm.Load("./tests/Chip8 Picture.ch8"); err != nil {
+	if err = m.Load(c.opts.ROM); err != nil {
 		// Error encountered while loading file, return
 		c.errC <- err
 		return
 	}
 
-	// Initialize a new instance of Pixel
-	if p, err = newPixel(c.screenMultiplier); err != nil {
-		// Error encountered while initializing pixel, return
+	if r, err = c.newRenderer(); err != nil {
+		// Error encountered while initializing renderer, return
 		c.errC <- err
 		return
 	}
 
-	// Initialize VM
-	vm.Initialize(p)
+	if c.opts.RecordInput != "" {
+		// Record the keypad so the session can be replayed, such as by chip8video
+		input = vm.NewInputRecorder(r, c.opts.Seed)
+		r = input
+	}
+
+	if closer, ok := r.(io.Closer); ok {
+		// Release the renderer once the machine has stopped
+		defer closer.Close()
+	}
+
+	// Initialize machine
+	m.Initialize(r)
+
+	// Run the machine
+	err = m.Run(c.ctx)
+	if input != nil {
+		// Machine has stopped, write the input recording
+		c.saveInput(input.Recording())
+	}
+
+	if c.wav != nil {
+		// Machine has stopped, finish the WAV file
+		c.closeWAV()
+	}
+
+	// Pass the returning value to the error channel
+	c.errC <- err
+}
+
+// wait will wait for run to return
+func (c *Chip8) wait() {
+	c.wg.Wait()
+}
+
+func (c *Chip8) closeWAV() {
+	if err := c.wav.Close(); err != nil {
+		out.Errorf("error writing WAV file: %v", err)
+		return
+	}
+
+	out.Successf("Buzzer audio written to %s", c.opts.WAV)
+}
+
+func (c *Chip8) saveInput(rec vm.InputRecording) {
+	if err := rec.Save(c.opts.RecordInput); err != nil {
+		out.Errorf("error writing input recording: %v", err)
+		return
+	}
+
+	out.Successf("Input recording written to %s", c.opts.RecordInput)
+}
+
+func (c *Chip8) newRenderer() (r vm.Renderer, err error) {
+	if c.opts.Headless && c.opts.Screenshot != "" {
+		// Run without a window until the screenshot has been taken
+		return newScreenshotRenderer(c.opts.Screenshot, c.opts.ScreenshotFrames, c.opts.ScreenMultiplier), nil
+	}
+
+	if c.opts.Headless {
+		// Run without a window
+		return vm.NewHeadlessRenderer(), nil
+	}
+
+	if c.opts.Terminal != "" {
+		// Draw to the terminal instead of a window
+		return newTerminal(c.opts.Terminal, c.opts.TerminalKeyHold)
+	}
+
+	// Initialize a new instance of Pixel
+	var p *PixelRenderer
+	if p, err = newPixel(c.opts.ScreenMultiplier); err != nil {
+		return
+	}
+
+	// Record the frames drawn to the window when the recording hotkey is pressed
+	p.recorder = vm.NewGIFRecorder(p, vm.ImageOptions{Scale: int(c.opts.ScreenMultiplier)})
+	return p.recorder, nil
+}
+
+func (c *Chip8) newMachine() (m machine, err error) {
+	if c.opts.VIPInterpreter != "" {
+		// Interpreter image has been provided, emulate the full COSMAC VIP
+		return c.newVIP()
+	}
+
+	var v vm.VM
+	// Set interpreter quirks before loading, as they determine the memory size
+	if err = v.SetQuirks(c.opts.Quirks); err != nil {
+		return
+	}
+
+	if c.opts.InstructionsPerFrame != 0 {
+		// Instructions per frame have been provided, set CPU speed
+		if err = v.SetInstructionsPerFrame(c.opts.InstructionsPerFrame); err != nil {
+			return
+		}
+	}
+
+	// Execute compiled blocks instead of interpreting when requested
+	v.SetRecompiler(c.opts.Recompile)
+
+	if c.opts.Seed != 0 {
+		// Seed has been provided, use a reproducible random source
+		v.SetRandom(vm.NewRandom(c.opts.Seed))
+	}
+
+	if c.opts.WAV != "" {
+		// WAV file has been provided, write the buzzer to it
+		if c.wav, err = vm.CreateWAVAudio(c.opts.WAV, vm.NewSquareWave(0, 0, 0)); err != nil {
+			return
+		}
+
+		v.SetAudio(c.wav)
+	}
+
+	return &v, nil
+}
+
+func (c *Chip8) newVIP() (m machine, err error) {
+	var monitor, interpreter []byte
+	if monitor, err = ioutil.ReadFile(c.opts.VIPMonitor); err != nil {
+		return
+	}
+
+	if interpreter, err = ioutil.ReadFile(c.opts.VIPInterpreter); err != nil {
+		return
+	}
+
+	return cosmac.NewVIP(monitor, interpreter)
+}
+
+// machine is a Chip8 implementation which can be run by Chip8
+type machine interface {
+	Load(filename string) error
+	Initialize(r vm.Renderer)
+	Run(ctx context.Context) error
+}
+
+// Options are the options used to run a Chip8 program
+type Options struct {
+	// ROM is the path of the program to load
+	ROM string
+	// ScreenMultiplier is how many true pixels represent each single Chip8 pixel
+	ScreenMultiplier float64
+	// Seed is the seed for the random number generator, zero uses a time based seed
+	Seed int64
+	// Quirks are the interpreter quirks to run the program with
+	Quirks vm.Quirks
+	// InstructionsPerFrame is the number of instructions executed per 60Hz frame, zero uses the default
+	InstructionsPerFrame int
+	// Headless runs the program without a window, the display and keypad are not connected to anything
+	Headless bool
+	// Screenshot is the path of a PNG file which is written after ScreenshotFrames frames when running headless
+	Screenshot string
+	// ScreenshotFrames is the number of frames to run before the screenshot is written
+	ScreenshotFrames int
+	// Terminal draws to the terminal instead of a window, using the halfblock or braille mode
+	Terminal string
+	// TerminalKeyHold is how long a key stays pressed after the terminal last received it, DefaultKeyHold is used when zero
+	// It should be longer than the auto-repeat delay of the terminal
+	TerminalKeyHold time.Duration
+	// RecordInput is the path of a file the keypad input is recorded to, it is written once the program stops
+	RecordInput string
+	// WAV is the path of a WAV file the buzzer is written to, it is finished once the program stops
+	WAV string
+	// Recompile executes the program as compiled blocks instead of interpreting each instruction
+	Recompile bool
 
-	// Run the VM and pass the returning value to the error channel
-	c.errC <- vm.Run(c.ctx)
+	// VIPMonitor is the path of a COSMAC VIP monitor ROM image
+	VIPMonitor string
+	// VIPInterpreter is the path of a COSMAC VIP CHIP-8 interpreter image, when set the full machine is emulated
+	VIPInterpreter string
 }
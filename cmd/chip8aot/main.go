@@ -0,0 +1,61 @@
+// chip8aot translates a CHIP-8 program into a Go package which implements vm.Native
+//
+//	chip8aot -rom "./tests/Chip8 Picture.ch8" -package picture -out ./picture/picture.go
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hatchify/scribe"
+	"github.com/itsmontoya/chip8/aot"
+)
+
+var out = scribe.New("Chip8 AOT")
+
+func main() {
+	var (
+		rom    string
+		target string
+		start  uint
+		opts   aot.Options
+		err    error
+	)
+
+	flag.StringVar(&rom, "rom", "", "Path of the Chip8 program to translate.")
+	flag.StringVar(&target, "out", "", "Path of the Go file to write, leave empty to write to stdout.")
+	flag.StringVar(&opts.Package, "package", "program", "Name of the generated Go package.")
+	flag.UintVar(&start, "start", 0x200, "Address the program is loaded to.")
+	flag.Parse()
+
+	var program []byte
+	if program, err = ioutil.ReadFile(rom); err != nil {
+		exit(err)
+	}
+
+	opts.Source = filepath.Base(rom)
+	opts.Start = uint16(start)
+
+	var src []byte
+	if src, err = aot.Translate(program, opts); err != nil {
+		exit(err)
+	}
+
+	if target == "" {
+		_, err = os.Stdout.Write(src)
+		exit(err)
+	}
+
+	exit(ioutil.WriteFile(target, src, 0644))
+}
+
+func exit(err error) {
+	if err == nil {
+		os.Exit(0)
+	}
+
+	out.Errorf("error encountered: %v", err)
+	os.Exit(1)
+}
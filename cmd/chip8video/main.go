@@ -0,0 +1,107 @@
+// chip8video runs a CHIP-8 program headless while replaying a recorded input file and exports it as an AVI video
+// Input files are recorded with the -recordInput flag of chip8
+//
+//	chip8video -rom ./game.ch8 -input ./game.input -out ./game.avi -scale 8
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/hatchify/scribe"
+	"github.com/itsmontoya/chip8/video"
+	"github.com/itsmontoya/chip8/vm"
+)
+
+var out = scribe.New("Chip8 Video")
+
+func main() {
+	var (
+		rom        string
+		inputFile  string
+		target     string
+		quirksName string
+		ipf        int
+		seed       int64
+		opts       video.Options
+		err        error
+	)
+
+	flag.StringVar(&rom, "rom", "", "Path of the Chip8 program to run.")
+	flag.StringVar(&inputFile, "input", "", "Path of the recorded input file to replay, leave empty to run without input.")
+	flag.StringVar(&target, "out", "chip8.avi", "Path of the AVI file to write.")
+	flag.IntVar(&opts.Image.Scale, "scale", 8, "How many video pixels represent each single Chip8 pixel of the largest resolution.")
+	flag.IntVar(&opts.Frames, "frames", 0, "How many frames to export, zero stops two seconds after the last input event.")
+	flag.IntVar(&opts.Quality, "quality", 90, "JPEG quality of the video frames (1 - 100).")
+	flag.IntVar(&opts.SampleRate, "sampleRate", vm.DefaultSampleRate, "Audio sample rate in Hz.")
+	flag.Float64Var(&opts.Frequency, "frequency", vm.DefaultFrequency, "Frequency of the buzzer in Hz.")
+	flag.Float64Var(&opts.Volume, "volume", vm.DefaultVolume, "Volume of the buzzer (0 - 1).")
+	flag.StringVar(&quirksName, "quirks", "", "Interpreter quirks to emulate (vip, eti660, chip48, schip, xochip or megachip), leave empty for the defaults.")
+	flag.IntVar(&ipf, "instructionsPerFrame", vm.DefaultInstructionsPerFrame, "How many instructions are executed per 60Hz frame, must match the recorded session.")
+	flag.Int64Var(&seed, "seed", 0, "Seed for the random number generator, overrides the seed of the input file when set.")
+	flag.Parse()
+
+	var input vm.InputRecording
+	if inputFile != "" {
+		if input, err = vm.LoadInputRecording(inputFile); err != nil {
+			exit(err)
+		}
+	}
+
+	if seed != 0 {
+		input.Seed = seed
+	}
+
+	var v vm.VM
+	if quirksName != "" {
+		var q vm.Quirks
+		if q, err = vm.GetQuirks(quirksName); err != nil {
+			exit(err)
+		}
+
+		// Set interpreter quirks before loading, as they determine the memory size
+		if err = v.SetQuirks(q); err != nil {
+			exit(err)
+		}
+
+		if quirksName == "megachip" {
+			// MegaChip programs draw at 256x192
+			opts.Width, opts.Height = 256, 192
+		}
+	}
+
+	if err = v.SetInstructionsPerFrame(ipf); err != nil {
+		exit(err)
+	}
+
+	if err = v.Load(rom); err != nil {
+		exit(err)
+	}
+
+	var f *os.File
+	if f, err = os.Create(target); err != nil {
+		exit(err)
+	}
+
+	var frames int
+	if frames, err = video.Export(f, &v, input, opts); err != nil {
+		// Close the file before exiting, as deferred calls do not run on exit
+		f.Close()
+		exit(err)
+	}
+
+	if err = f.Close(); err != nil {
+		exit(err)
+	}
+
+	out.Successf("Exported %d frames to %s", frames, target)
+}
+
+func exit(err error) {
+	if err == nil {
+		os.Exit(0)
+	}
+
+	out.Errorf("error encountered: %v", err)
+	os.Exit(1)
+}
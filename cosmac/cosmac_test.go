@@ -0,0 +1,145 @@
+package cosmac
+
+import "testing"
+
+func TestCPU_Step(t *testing.T) {
+	var b testBus
+	copy(b.memory[:], []byte{
+		0xF8, 0xF0, // LDI 0xF0
+		0xFC, 0x20, // ADI 0x20, D = 0x10 with a carry
+		0x33, 0x07, // BDF 0x07
+		0x00,       // IDL, skipped
+		0xA5,       // PLO R5
+		0xFF, 0x11, // SMI 0x11, D = 0xFF with a borrow
+		0xC7,       // LSNF, skips the next two bytes
+		0xF8, 0x00, // LDI 0x00, skipped
+		0x7B, // SEQ
+		0x00, // IDL
+	})
+
+	c := newCPU(&b)
+	var cycles int
+	for !c.idle {
+		cycles += c.Step()
+	}
+
+	if c.r[5] != 0x10 {
+		t.Fatalf("invalid R5, expected 0x10 and received 0x%02X", c.r[5])
+	}
+
+	if c.d != 0xFF || c.df != 0 {
+		t.Fatalf("invalid D and DF, received 0x%02X and %d", c.d, c.df)
+	}
+
+	if !c.Q() {
+		t.Fatal("Q was not set")
+	}
+
+	// LDI, ADI, BDF, PLO, SMI and SEQ take 2 cycles, LSNF takes 3 and IDL takes 2
+	if cycles != 6*2+3+2 {
+		t.Fatalf("invalid number of cycles, expected 17 and received %d", cycles)
+	}
+}
+
+func TestCPU_markAndReturn(t *testing.T) {
+	var b testBus
+	c := newCPU(&b)
+	c.r[2] = 0x80
+	c.x = 4
+	c.p = 0
+
+	// MARK saves X and P to T and the stack, then sets X to P
+	b.memory[0] = 0x79
+	c.Step()
+	if c.t != 0x40 || b.memory[0x80] != 0x40 || c.x != 0 || c.r[2] != 0x7F {
+		t.Fatalf("invalid MARK state, received T 0x%02X, X %d and R2 0x%02X", c.t, c.x, c.r[2])
+	}
+
+	// RET restores X and P from memory at R(X) and enables interrupts
+	c.r[0] = 0x10
+	c.ie = false
+	b.memory[0x10] = 0x70
+	c.x = 2
+	c.r[2] = 0x80
+	c.Step()
+	if c.x != 4 || c.p != 0 || !c.ie {
+		t.Fatalf("invalid RET state, received X %d, P %d and IE %v", c.x, c.p, c.ie)
+	}
+}
+
+func TestVIP_RunFrame(t *testing.T) {
+	monitor := []byte{
+		0xC0, 0x80, 0x03, // LBR 0x8003, unmaps the ROM from address 0
+		0xC0, 0x00, 0x00, // LBR 0x0000, continue in RAM
+	}
+
+	interpreter := make([]byte, 0x100)
+	copy(interpreter, []byte{
+		0xF8, 0x00, 0xB3, 0xF8, 0x10, 0xA3, // R3 = 0x0010
+		0xD3, // SEP R3
+	})
+
+	copy(interpreter[0x10:], []byte{
+		0xF8, 0x00, 0xB1, 0xF8, 0x30, 0xA1, // R1 = 0x0030, interrupt routine
+		0xF8, 0x00, 0xB2, 0xF8, 0xF0, 0xA2, // R2 = 0x00F0, stack
+		0xE2,       // SEX R2
+		0x69,       // INP 1, display on
+		0x30, 0x1E, // BR 0x1E
+	})
+
+	copy(interpreter[0x2F:], []byte{
+		0x70,       // RET
+		0x22,       // DEC R2
+		0x78,       // SAV
+		0xF8, 0x01, // LDI 0x01
+		0xB0,       // PHI R0
+		0xF8, 0x00, // LDI 0x00
+		0xA0,       // PLO R0, display starts at 0x0100
+		0x30, 0x2F, // BR 0x2F
+	})
+
+	v, err := NewVIP(monitor, interpreter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < displayLines*8; i++ {
+		v.ram[programStart-0x100+i] = byte(i)
+	}
+
+	v.RunFrame()
+	g := v.Graphics()
+	// Second byte of the first line is 0x01
+	for x := 8; x < 16; x++ {
+		expected := byte(0)
+		if x == 15 {
+			expected = 1
+		}
+
+		if g.Get(x) != expected {
+			t.Fatalf("invalid pixel (%d, 0), expected %d and received %d", x, expected, g.Get(x))
+		}
+	}
+
+	// First byte of the last line is 0xF8
+	if g.Get(127*64) != 1 || g.Get(127*64+5) != 0 {
+		t.Fatal("invalid last line")
+	}
+
+	// Keypad is read through the key latch and EF3
+	v.keypad.Set(0xA, true)
+	v.Output(2, 0xA)
+	if !v.Flag(3) {
+		t.Fatal("latched key was not reported as pressed")
+	}
+}
+
+type testBus struct {
+	memory [65536]byte
+}
+
+func (b *testBus) Read(address uint16) byte     { return b.memory[address] }
+func (b *testBus) Write(address uint16, v byte) { b.memory[address] = v }
+func (b *testBus) Output(port byte, v byte)     {}
+func (b *testBus) Input(port byte) byte         { return 0 }
+func (b *testBus) Flag(n byte) bool             { return false }
@@ -0,0 +1,405 @@
+package cosmac
+
+// Bus is the memory and I/O the CPU is connected to
+type Bus interface {
+	// Read will return the byte at the provided address
+	Read(address uint16) byte
+	// Write will set the byte at the provided address
+	Write(address uint16, b byte)
+
+	// Output is called by OUT 1-7 with the byte read from memory
+	Output(port byte, b byte)
+	// Input is called by INP 1-7, the returned byte is stored in memory and D
+	Input(port byte) byte
+
+	// Flag will return the state of the external flag lines EF1-EF4
+	Flag(n byte) bool
+}
+
+func newCPU(bus Bus) *CPU {
+	var c CPU
+	c.bus = bus
+	c.Reset()
+	return &c
+}
+
+// CPU emulates an RCA CDP1802
+type CPU struct {
+	// Scratchpad registers
+	r [16]uint16
+
+	// Data register
+	d byte
+	// Data flag (carry)
+	df byte
+	// Program counter designator
+	p byte
+	// Data pointer designator
+	x byte
+	// Holds X and P during an interrupt
+	t byte
+
+	// Interrupt enable
+	ie bool
+	// Q output
+	q bool
+	// Set by IDL, cleared by DMA and interrupts
+	idle bool
+
+	bus Bus
+}
+
+// Reset will reset the CPU as the CLEAR input does, execution starts at address 0 with R0 as the program counter
+func (c *CPU) Reset() {
+	c.r[0] = 0
+	c.p = 0
+	c.x = 0
+	c.q = false
+	c.ie = true
+	c.idle = false
+}
+
+// Q will return the state of the Q output
+func (c *CPU) Q() bool {
+	return c.q
+}
+
+// Interrupt will service an interrupt request, it returns false when interrupts are disabled
+func (c *CPU) Interrupt() (ok bool) {
+	if !c.ie {
+		return false
+	}
+
+	// Save X and P, then use R1 as the program counter and R2 as the data pointer
+	c.t = c.x<<4 | c.p
+	c.p = 1
+	c.x = 2
+	c.ie = false
+	c.idle = false
+	return true
+}
+
+// DMAOut will perform a DMA out cycle, returning the byte at R0 and incrementing R0
+func (c *CPU) DMAOut() (b byte) {
+	b = c.bus.Read(c.r[0])
+	c.r[0]++
+	c.idle = false
+	return
+}
+
+// Step will execute a single instruction and return the number of machine cycles it took
+// An idle CPU takes a single machine cycle without executing anything
+func (c *CPU) Step() (cycles int) {
+	if c.idle {
+		return 1
+	}
+
+	op := c.fetch()
+	n := op & 0x0F
+	switch op >> 4 {
+	case 0x0:
+		if n == 0 {
+			// IDL
+			c.idle = true
+			return 2
+		}
+
+		// LDN
+		c.d = c.bus.Read(c.r[n])
+	case 0x1:
+		// INC
+		c.r[n]++
+	case 0x2:
+		// DEC
+		c.r[n]--
+	case 0x3:
+		c.shortBranch(n)
+	case 0x4:
+		// LDA
+		c.d = c.bus.Read(c.r[n])
+		c.r[n]++
+	case 0x5:
+		// STR
+		c.bus.Write(c.r[n], c.d)
+	case 0x6:
+		c.inputOutput(n)
+	case 0x7:
+		c.control(n)
+	case 0x8:
+		// GLO
+		c.d = byte(c.r[n])
+	case 0x9:
+		// GHI
+		c.d = byte(c.r[n] >> 8)
+	case 0xA:
+		// PLO
+		c.r[n] = c.r[n]&0xFF00 | uint16(c.d)
+	case 0xB:
+		// PHI
+		c.r[n] = c.r[n]&0x00FF | uint16(c.d)<<8
+	case 0xC:
+		c.longBranch(n)
+		return 3
+	case 0xD:
+		// SEP
+		c.p = n
+	case 0xE:
+		// SEX
+		c.x = n
+	case 0xF:
+		c.alu(n)
+	}
+
+	return 2
+}
+
+func (c *CPU) fetch() (b byte) {
+	b = c.bus.Read(c.r[c.p])
+	c.r[c.p]++
+	return
+}
+
+// immediate will return the byte following the instruction
+func (c *CPU) immediate() byte {
+	return c.fetch()
+}
+
+func (c *CPU) shortBranch(n byte) {
+	var condition bool
+	switch n & 0x07 {
+	case 0x0:
+		// BR, SKP when negated
+		condition = true
+	case 0x1:
+		// BQ, BNQ when negated
+		condition = c.q
+	case 0x2:
+		// BZ, BNZ when negated
+		condition = c.d == 0
+	case 0x3:
+		// BDF, BNF when negated
+		condition = c.df == 1
+
+	default:
+		// B1-B4, BN1-BN4 when negated
+		condition = c.bus.Flag(n&0x07 - 3)
+	}
+
+	if n&0x08 != 0 {
+		condition = !condition
+	}
+
+	if !condition {
+		// Skip the branch address
+		c.r[c.p]++
+		return
+	}
+
+	// Replace the low byte of the program counter
+	c.r[c.p] = c.r[c.p]&0xFF00 | uint16(c.bus.Read(c.r[c.p]))
+}
+
+func (c *CPU) longBranch(n byte) {
+	var condition bool
+	switch n & 0x03 {
+	case 0x0:
+		// LBR, LSKP when negated
+		condition = true
+	case 0x1:
+		// LBQ, LBNQ when negated
+		condition = c.q
+	case 0x2:
+		// LBZ, LBNZ when negated
+		condition = c.d == 0
+	case 0x3:
+		// LBDF, LBNF when negated
+		condition = c.df == 1
+	}
+
+	if n&0x08 != 0 {
+		condition = !condition
+	}
+
+	if n&0x04 == 0 {
+		// Long branch
+		if !condition {
+			c.r[c.p] += 2
+			return
+		}
+
+		address := uint16(c.bus.Read(c.r[c.p]))<<8 | uint16(c.bus.Read(c.r[c.p]+1))
+		c.r[c.p] = address
+		return
+	}
+
+	// Long skips, the condition is inverted compared to branches
+	switch n {
+	case 0x4:
+		// NOP
+		return
+	case 0xC:
+		// LSIE
+		condition = c.ie
+	case 0x5, 0xD:
+		// LSNQ, LSQ
+		condition = c.q == (n == 0xD)
+	case 0x6, 0xE:
+		// LSNZ, LSZ
+		condition = (c.d == 0) == (n == 0xE)
+	case 0x7, 0xF:
+		// LSNF, LSDF
+		condition = (c.df == 1) == (n == 0xF)
+	}
+
+	if condition {
+		c.r[c.p] += 2
+	}
+}
+
+func (c *CPU) inputOutput(n byte) {
+	switch {
+	case n == 0:
+		// IRX
+		c.r[c.x]++
+	case n < 8:
+		// OUT
+		c.bus.Output(n, c.bus.Read(c.r[c.x]))
+		c.r[c.x]++
+	case n > 8:
+		// INP
+		c.d = c.bus.Input(n - 8)
+		c.bus.Write(c.r[c.x], c.d)
+	}
+}
+
+func (c *CPU) control(n byte) {
+	switch n {
+	case 0x0, 0x1:
+		// RET, DIS
+		t := c.bus.Read(c.r[c.x])
+		c.r[c.x]++
+		c.x = t >> 4
+		c.p = t & 0x0F
+		c.ie = n == 0x0
+	case 0x2:
+		// LDXA
+		c.d = c.bus.Read(c.r[c.x])
+		c.r[c.x]++
+	case 0x3:
+		// STXD
+		c.bus.Write(c.r[c.x], c.d)
+		c.r[c.x]--
+	case 0x4:
+		// ADC
+		c.add(c.bus.Read(c.r[c.x]), c.d, c.df)
+	case 0x5:
+		// SDB
+		c.subtract(c.bus.Read(c.r[c.x]), c.d, c.df)
+	case 0x6:
+		// SHRC
+		df := c.d & 0x01
+		c.d = c.d>>1 | c.df<<7
+		c.df = df
+	case 0x7:
+		// SMB
+		c.subtract(c.d, c.bus.Read(c.r[c.x]), c.df)
+	case 0x8:
+		// SAV
+		c.bus.Write(c.r[c.x], c.t)
+	case 0x9:
+		// MARK
+		c.t = c.x<<4 | c.p
+		c.bus.Write(c.r[2], c.t)
+		c.x = c.p
+		c.r[2]--
+	case 0xA:
+		// REQ
+		c.q = false
+	case 0xB:
+		// SEQ
+		c.q = true
+	case 0xC:
+		// ADCI
+		c.add(c.immediate(), c.d, c.df)
+	case 0xD:
+		// SDBI
+		c.subtract(c.immediate(), c.d, c.df)
+	case 0xE:
+		// SHLC
+		df := c.d >> 7
+		c.d = c.d<<1 | c.df
+		c.df = df
+	case 0xF:
+		// SMBI
+		c.subtract(c.d, c.immediate(), c.df)
+	}
+}
+
+func (c *CPU) alu(n byte) {
+	var operand byte
+	switch {
+	case n == 0x6 || n == 0xE:
+		// Shifts have no operand
+	case n >= 0x8:
+		// Immediate instructions use the byte following the instruction
+		operand = c.immediate()
+
+	default:
+		operand = c.bus.Read(c.r[c.x])
+	}
+
+	switch n {
+	case 0x0, 0x8:
+		// LDX, LDI
+		c.d = operand
+	case 0x1, 0x9:
+		// OR, ORI
+		c.d |= operand
+	case 0x2, 0xA:
+		// AND, ANI
+		c.d &= operand
+	case 0x3, 0xB:
+		// XOR, XRI
+		c.d ^= operand
+	case 0x4, 0xC:
+		// ADD, ADI
+		c.add(operand, c.d, 0)
+	case 0x5, 0xD:
+		// SD, SDI
+		c.subtract(operand, c.d, 1)
+	case 0x6:
+		// SHR
+		c.df = c.d & 0x01
+		c.d >>= 1
+	case 0x7, 0xF:
+		// SM, SMI
+		c.subtract(c.d, operand, 1)
+	case 0xE:
+		// SHL
+		c.df = c.d >> 7
+		c.d <<= 1
+	}
+}
+
+// add will set D to a + b + carry, DF is set to the carry out
+func (c *CPU) add(a, b, carry byte) {
+	sum := uint16(a) + uint16(b) + uint16(carry)
+	c.d = byte(sum)
+	c.df = byte(sum >> 8)
+}
+
+// subtract will set D to a - b - borrow, where the borrow is the inverse of notBorrow
+// DF is set to 1 when there is no borrow, and to 0 when there is
+func (c *CPU) subtract(a, b, notBorrow byte) {
+	diff := int(a) - int(b) - int(1-notBorrow)
+	c.d = byte(diff)
+	c.df = boolToByte(diff >= 0)
+}
+
+func boolToByte(val bool) byte {
+	if val {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,67 @@
+package cosmac
+
+import "github.com/itsmontoya/chip8/vm"
+
+const (
+	// Machine cycles per horizontal line
+	cyclesPerLine = 14
+	// Horizontal lines per frame
+	linesPerFrame = 262
+
+	// The interrupt is requested two lines before the first display line
+	interruptLine = 78
+	// First line which is displayed
+	firstDisplayLine = 80
+	// Number of displayed lines
+	displayLines = 128
+	// DMA out cycles per displayed line, each transfers 8 pixels
+	dmaCyclesPerLine = 8
+
+	displayWidth = dmaCyclesPerLine * 8
+)
+
+func newVideo() (v video) {
+	v.graphics = vm.NewGraphics(displayWidth, displayLines)
+	return
+}
+
+// video emulates the CDP1861 video display controller
+type video struct {
+	// Set by INP 1, cleared by OUT 1
+	enabled bool
+	// Current horizontal line
+	line int
+
+	graphics vm.Graphics
+}
+
+// isDisplayLine will return whether or not the current line is displayed, which takes DMA cycles from the CPU
+func (v *video) isDisplayLine() bool {
+	return v.enabled && v.line >= firstDisplayLine && v.line < firstDisplayLine+displayLines
+}
+
+// isInterruptLine will return whether or not an interrupt is requested on the current line
+func (v *video) isInterruptLine() bool {
+	return v.enabled && v.line >= interruptLine && v.line < firstDisplayLine
+}
+
+// flag will return the state of EF1, which is asserted for the four lines before and the last four lines of the display
+func (v *video) flag() bool {
+	switch {
+	case v.line >= firstDisplayLine-4 && v.line < firstDisplayLine:
+		return true
+	case v.line >= firstDisplayLine+displayLines-4 && v.line < firstDisplayLine+displayLines:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// setByte will set the 8 pixels of the current line transferred by a DMA out cycle
+func (v *video) setByte(column int, b byte) {
+	row := (v.line - firstDisplayLine) * displayWidth
+	for bit := 0; bit < 8; bit++ {
+		v.graphics.Set(row+column*8+bit, (b>>uint(7-bit))&0x01)
+	}
+}
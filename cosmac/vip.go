@@ -0,0 +1,250 @@
+package cosmac
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"time"
+
+	"github.com/itsmontoya/chip8/vm"
+)
+
+var (
+	// ErrInvalidMonitor is returned when a monitor ROM image larger than 512 bytes is provided
+	ErrInvalidMonitor = errors.New("invalid monitor, image must be at most 512 bytes")
+	// ErrInvalidInterpreter is returned when an interpreter image which does not fit below 0x200 is provided
+	ErrInvalidInterpreter = errors.New("invalid interpreter, image must be at most 512 bytes")
+	// ErrProgramTooLarge is returned when a program does not fit in memory
+	ErrProgramTooLarge = errors.New("program is too large to fit in memory")
+	// ErrRendererNotSet is returned when a VIPs Renderer has not been set before calling VIP.Run
+	ErrRendererNotSet = errors.New("cannot run, renderer not set")
+)
+
+const (
+	// Size of the monitor ROM
+	romSize = 512
+	// Size of the RAM, it is mirrored throughout the lower half of the address space
+	ramSize = 4096
+	// Address programs are loaded to, directly after the CHIP-8 interpreter
+	programStart = 0x200
+	// Frames per second of the CDP1861 with the VIP's 1.76 MHz clock
+	framesPerSecond = 60
+)
+
+// NewVIP will return a new VIP running the provided monitor ROM and CHIP-8 interpreter images
+// The images are not bundled, they must be dumped from a real machine or the VIP manual listings
+func NewVIP(monitor, interpreter []byte) (vp *VIP, err error) {
+	if len(monitor) > romSize {
+		return nil, ErrInvalidMonitor
+	}
+
+	if len(interpreter) > programStart {
+		return nil, ErrInvalidInterpreter
+	}
+
+	var v VIP
+	copy(v.rom[:], monitor)
+	copy(v.ram[:], interpreter)
+	v.video = newVideo()
+	v.cpu = newCPU(&v)
+	v.Reset()
+	return &v, nil
+}
+
+// VIP emulates an RCA COSMAC VIP running the original CHIP-8 interpreter
+// Machine code subroutines called by 0NNN run on the emulated CDP1802, so hybrid programs are supported
+type VIP struct {
+	cpu   *CPU
+	video video
+
+	rom [romSize]byte
+	ram [ramSize]byte
+	// Set on reset, the monitor ROM is mapped to address 0 until an address with A15 set is accessed
+	romAtZero bool
+
+	// Key selected by OUT 2, its state is reported on EF3
+	keyLatch byte
+	keypad   vm.Keypad
+
+	// Machine cycles executed past the end of the previous line
+	cycleDebt int
+
+	// Renderer
+	r vm.Renderer
+}
+
+// Reset will reset the machine as the RUN switch does, the monitor starts and runs the interpreter
+func (v *VIP) Reset() {
+	v.cpu.Reset()
+	v.romAtZero = true
+	v.video.enabled = false
+	v.cycleDebt = 0
+}
+
+// Load will load a program into RAM at 0x200
+func (v *VIP) Load(filename string) (err error) {
+	var bs []byte
+	// Read provided program file
+	if bs, err = ioutil.ReadFile(filename); err != nil {
+		return
+	}
+
+	if len(bs) > ramSize-programStart {
+		// Program does not fit in memory, return
+		return ErrProgramTooLarge
+	}
+
+	// Copy program bytes to memory starting at 0x200
+	copy(v.ram[programStart:], bs)
+	return
+}
+
+// Initialize will set the Renderer used to display frames and read the keypad
+func (v *VIP) Initialize(r vm.Renderer) {
+	v.r = r
+}
+
+// Run will run the VIP in real time until the context expires
+func (v *VIP) Run(ctx context.Context) (err error) {
+	if v.r == nil {
+		err = ErrRendererNotSet
+		return
+	}
+
+	updater, _ := v.r.(vm.Updater)
+	tkr := time.NewTicker(time.Second / framesPerSecond)
+	defer tkr.Stop()
+	for range tkr.C {
+		if isDone(ctx) {
+			// Context is finished, return
+			return
+		}
+
+		v.RunFrame()
+		if err = v.r.Draw(v.video.graphics); err != nil {
+			return
+		}
+
+		if updater != nil {
+			// Renderer needs to run every frame
+			if err = updater.Update(); err != nil {
+				return
+			}
+		}
+
+		v.keypad = v.r.GetKeypad()
+	}
+
+	return
+}
+
+// RunFrame will emulate a single CDP1861 frame
+func (v *VIP) RunFrame() {
+	for line := 0; line < linesPerFrame; line++ {
+		v.video.line = line
+		budget := cyclesPerLine
+		if v.video.isDisplayLine() {
+			// Display lines start with the DMA out cycles which fetch the pixels of the line
+			for column := 0; column < dmaCyclesPerLine; column++ {
+				v.video.setByte(column, v.cpu.DMAOut())
+			}
+
+			budget -= dmaCyclesPerLine
+		}
+
+		v.execute(budget)
+	}
+}
+
+// Graphics will return the current display, 64 pixels wide and 128 lines tall
+func (v *VIP) Graphics() vm.Graphics {
+	return v.video.graphics
+}
+
+// Tone will return whether or not the tone generator is on, it is driven by the Q output
+func (v *VIP) Tone() bool {
+	return v.cpu.Q()
+}
+
+// Read will return the byte at the provided address
+func (v *VIP) Read(address uint16) byte {
+	if address&0x8000 != 0 {
+		// Accessing the upper half of the address space unmaps the ROM from address 0
+		v.romAtZero = false
+		return v.rom[address%romSize]
+	}
+
+	if v.romAtZero {
+		return v.rom[address%romSize]
+	}
+
+	return v.ram[address%ramSize]
+}
+
+// Write will set the byte at the provided address, writes to the ROM are ignored
+func (v *VIP) Write(address uint16, b byte) {
+	if address&0x8000 != 0 {
+		v.romAtZero = false
+		return
+	}
+
+	v.ram[address%ramSize] = b
+}
+
+// Output will handle OUT 1 (display off) and OUT 2 (keypad latch)
+func (v *VIP) Output(port byte, b byte) {
+	switch port {
+	case 1:
+		v.video.enabled = false
+	case 2:
+		v.keyLatch = b & 0x0F
+	}
+}
+
+// Input will handle INP 1 (display on)
+func (v *VIP) Input(port byte) byte {
+	if port == 1 {
+		v.video.enabled = true
+	}
+
+	return 0
+}
+
+// Flag will return EF1 (display status) and EF3 (latched key pressed)
+func (v *VIP) Flag(n byte) bool {
+	switch n {
+	case 1:
+		return v.video.flag()
+	case 3:
+		return v.keypad[v.keyLatch] != 0
+
+	default:
+		return false
+	}
+}
+
+// execute will run instructions for the provided number of machine cycles
+// Instructions which run past the budget are paid back on the next line
+func (v *VIP) execute(budget int) {
+	for v.cycleDebt < budget {
+		if v.video.isInterruptLine() && v.cpu.Interrupt() {
+			// Interrupt acknowledged, which takes a single cycle
+			v.cycleDebt++
+			continue
+		}
+
+		v.cycleDebt += v.cpu.Step()
+	}
+
+	v.cycleDebt -= budget
+}
+
+func isDone(ctx context.Context) (done bool) {
+	select {
+	case <-ctx.Done():
+		return true
+
+	default:
+		return false
+	}
+}
@@ -3,11 +3,13 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	"github.com/Hatch1fy/errors"
 	"github.com/faiface/pixel/pixelgl"
 	"github.com/hatchify/closer"
 	"github.com/hatchify/scribe"
+	"github.com/itsmontoya/chip8/vm"
 )
 
 var (
@@ -16,19 +18,57 @@ var (
 )
 
 func main() {
-	var screenMultiplier float64
-	flag.Float64Var(&screenMultiplier, "screenMultiplier", 8, "How many true pixels represent each single Chip8 pixel.")
+	var (
+		opts       Options
+		quirksName string
+		err        error
+	)
+
+	flag.StringVar(&opts.ROM, "rom", "./tests/Chip8 Picture.ch8", "Path of the Chip8 program to run.")
+	flag.Float64Var(&opts.ScreenMultiplier, "screenMultiplier", 8, "How many true pixels represent each single Chip8 pixel.")
+	flag.Int64Var(&opts.Seed, "seed", 0, "Seed for the random number generator, a non-zero value makes runs reproducible.")
+	flag.IntVar(&opts.InstructionsPerFrame, "instructionsPerFrame", vm.DefaultInstructionsPerFrame, "How many instructions are executed per 60Hz frame, sets the CPU speed.")
+	flag.BoolVar(&opts.Headless, "headless", false, "Run without opening a window, for servers and automated runs.")
+	flag.StringVar(&opts.Screenshot, "screenshot", "", "Path of a PNG file to write after screenshotFrames frames when running headless, the program then stops.")
+	flag.IntVar(&opts.ScreenshotFrames, "screenshotFrames", 60, "How many frames to run before the headless screenshot is written.")
+	flag.StringVar(&opts.Terminal, "terminal", "", "Draw to the terminal instead of a window (halfblock or braille), keys are read from stdin.")
+	flag.DurationVar(&opts.TerminalKeyHold, "terminalKeyHold", DefaultKeyHold, "How long a key stays pressed after the terminal last received it, must be longer than the key repeat delay.")
+	flag.StringVar(&opts.RecordInput, "recordInput", "", "Path of a file to record the keypad input to, the session can then be exported as video with chip8video.")
+	flag.StringVar(&opts.WAV, "wav", "", "Path of a WAV file to write the buzzer to, as the window has no audio output.")
+	flag.BoolVar(&opts.Recompile, "recompile", false, "Execute the program as compiled blocks instead of interpreting each instruction.")
+	flag.StringVar(&opts.VIPMonitor, "vipMonitor", "", "Path of a COSMAC VIP monitor ROM image, used with vipInterpreter.")
+	flag.StringVar(&opts.VIPInterpreter, "vipInterpreter", "", "Path of a COSMAC VIP CHIP-8 interpreter image, emulates the full VIP with its 1802 CPU when set.")
+	flag.StringVar(&quirksName, "quirks", "", "Interpreter quirks to emulate (vip, eti660, chip48, schip, xochip or megachip), leave empty for the defaults.")
 	flag.Parse()
 
-	c := New(screenMultiplier)
+	if quirksName != "" {
+		if opts.Quirks, err = vm.GetQuirks(quirksName); err != nil {
+			exit(err)
+		}
+	}
+
+	if opts.RecordInput != "" && opts.Seed == 0 {
+		// Replaying the recording needs the same random numbers, use a known seed
+		opts.Seed = time.Now().UnixNano()
+	}
+
+	c := New(opts)
 	go func() {
 		err := close.Wait()
 		c.cancel()
+		// Let the machine stop and write its recordings before exiting
+		c.wait()
 		c.errC <- err
 	}()
 
-	pixelgl.Run(c.run)
-	err := <-c.errC
+	if opts.Headless || opts.Terminal != "" {
+		// Pixel is not needed, run directly
+		c.run()
+	} else {
+		pixelgl.Run(c.run)
+	}
+
+	err = <-c.errC
 	exit(err)
 }
 
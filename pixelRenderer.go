@@ -1,11 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"image/color"
+	"time"
 
 	"github.com/Hatch1fy/errors"
 	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/imdraw"
 	"github.com/faiface/pixel/pixelgl"
 	"github.com/itsmontoya/chip8/vm"
 	"golang.org/x/image/colornames"
@@ -20,11 +21,11 @@ func newPixel(screenMultiplier float64) (pp *PixelRenderer, err error) {
 		return
 	}
 
-	p.imd = imdraw.New(nil)
 	p.screenMultiplier = screenMultiplier
 	p.clearColor = colornames.Skyblue
-	p.offColor = color.RGBA{255, 255, 255, 0}
-	p.onColor = color.RGBA{255, 255, 255, 255}
+	p.colors = vm.DefaultColors
+	// Unset pixels are transparent so that the clear color shows through
+	p.colors[0] = color.RGBA{}
 
 	// Set reference to PixelRenderer
 	pp = &p
@@ -35,71 +36,124 @@ func newPixel(screenMultiplier float64) (pp *PixelRenderer, err error) {
 type PixelRenderer struct {
 	win *pixelgl.Window
 	cfg pixelgl.WindowConfig
-	imd *imdraw.IMDraw
-	g   vm.Graphics
+	// Framebuffer with one pixel for every Chip8 pixel, it is scaled to the window when drawn
+	// The canvas keeps a single texture which is updated every frame, it is only recreated when the resolution changes
+	canvas *pixelgl.Canvas
+	// Alpha-premultiplied RGBA bytes of the canvas, rows start at the bottom
+	pix []uint8
+	// Most recently drawn Graphics, used for screenshots
+	g vm.Graphics
+	// Records the window as a GIF, started and stopped with the recording hotkey
+	recorder *vm.GIFRecorder
 
 	screenMultiplier float64
 
 	clearColor color.RGBA
-	offColor   color.RGBA
-	onColor    color.RGBA
+	// Colors for each pixel value, XO-CHIP pixels can be set on two planes which results in four colors
+	colors [4]color.RGBA
 }
 
-func (p *PixelRenderer) drawPixel(i int, val byte) {
-	p.setColor(val)
-	x, y := getXY(i)
-	p.drawSquare(x, y)
-	p.g[i] = val
+// Draw will update the framebuffer from the Graphics and draw it to the window
+func (p *PixelRenderer) Draw(g vm.Graphics) (err error) {
+	if p.win.Closed() {
+		// Window has been closed, return
+		return errors.ErrIsClosed
+	}
+
+	p.g.Copy(g)
+	p.updateFramebuffer(g)
+	p.win.Clear(p.clearColor)
+
+	// Scale the framebuffer to fill the window, pixels are only square for 2:1 resolutions
+	scale := pixel.V(p.cfg.Bounds.W()/float64(g.Width()), p.cfg.Bounds.H()/float64(g.Height()))
+	p.canvas.Draw(p.win, pixel.IM.ScaledXY(pixel.ZV, scale).Moved(p.win.Bounds().Center()))
+
+	// Swap buffers only, input is polled once per frame by Update so that JustPressed is not reset before it is checked
+	p.win.SwapBuffers()
+	return
 }
 
-func (p *PixelRenderer) drawSquare(x, y float64) {
-	// Multiply X value by screen multiplier
-	x *= p.screenMultiplier
-	// Multiply Y value by screen multiplier
-	y *= p.screenMultiplier
-	// Inverse Y
-	y = p.cfg.Bounds.H() - y
-
-	// Bottom left corner
-	p.imd.Push(pixel.V(x+0, y+0))
-	// Bottom right corner
-	p.imd.Push(pixel.V(x+p.screenMultiplier, y+0))
-	// Top right corner
-	p.imd.Push(pixel.V(x+p.screenMultiplier, y-p.screenMultiplier))
-	// Top left corner
-	p.imd.Push(pixel.V(x+0, y-p.screenMultiplier))
-
-	// Complete shape
-	p.imd.Polygon(0)
-
-	// Draw shape to window buffers
-	p.imd.Draw(p.win)
+// Update will poll the window for input, it is called every frame including the frames which are not drawn
+func (p *PixelRenderer) Update() (err error) {
+	p.win.UpdateInput()
+	if p.win.Closed() {
+		// Window has been closed, return
+		return errors.ErrIsClosed
+	}
+
+	if p.win.JustPressed(pixelgl.KeyF12) {
+		// Screenshot hotkey has been pressed
+		p.screenshot()
+	}
+
+	if p.win.JustPressed(pixelgl.KeyF10) {
+		// Recording hotkey has been pressed
+		p.toggleRecording()
+	}
+
+	return
 }
 
-func (p *PixelRenderer) setColor(val byte) {
-	if val == 0 {
-		// Value is unset, use "off" color
-		p.imd.Color = p.offColor
+// screenshot will write the most recently drawn Graphics to a PNG file in the working directory
+func (p *PixelRenderer) screenshot() {
+	var opts vm.ImageOptions
+	opts.Scale = int(p.screenMultiplier)
+
+	filename := fmt.Sprintf("chip8-%s.png", time.Now().Format("20060102-150405.000"))
+	if err := p.g.SavePNG(filename, opts); err != nil {
+		out.Errorf("error writing screenshot: %v", err)
 		return
 	}
 
-	// Value is set, use "on" color
-	p.imd.Color = p.onColor
+	out.Successf("Screenshot written to %s", filename)
 }
 
-// Draw will draw to the screen
-func (p *PixelRenderer) Draw(g vm.Graphics) (err error) {
-	// Draw the pixels which changed in the new Graphics state
-	g.ForEachDelta(p.g, p.drawPixel)
+// toggleRecording will start recording a GIF, or stop recording and write it to a file in the working directory
+func (p *PixelRenderer) toggleRecording() {
+	if p.recorder == nil {
+		// Renderer is not being recorded, return
+		return
+	}
 
-	if p.win.Closed() {
-		// Window has been closed, return
-		return errors.ErrIsClosed
+	if !p.recorder.Recording() {
+		p.recorder.Start()
+		out.Notificationf("Recording started, press F10 to stop")
+		return
 	}
 
-	// Update window (swap buffers)
-	p.win.Update()
-	return
+	p.recorder.Stop()
+	filename := fmt.Sprintf("chip8-%s.gif", time.Now().Format("20060102-150405.000"))
+	if err := p.recorder.Save(filename); err != nil {
+		out.Errorf("error writing recording: %v", err)
+		return
+	}
+
+	out.Successf("Recording written to %s", filename)
+}
+
+func (p *PixelRenderer) updateFramebuffer(g vm.Graphics) {
+	width := g.Width()
+	height := g.Height()
+	if p.canvas == nil || int(p.canvas.Bounds().W()) != width || int(p.canvas.Bounds().H()) != height {
+		// Resolution has changed, create a framebuffer of the new size
+		p.canvas = pixelgl.NewCanvas(pixel.R(0, 0, float64(width), float64(height)))
+		p.pix = make([]uint8, width*height*4)
+	}
+
+	for i := 0; i < g.Len(); i++ {
+		x := i % width
+		y := i / width
+		// Canvas rows start at the bottom, Graphics rows start at the top
+		offset := ((height-1-y)*width + x) * 4
+		c := g.DisplayColor(i, p.colors)
+		p.pix[offset+0] = c.R
+		p.pix[offset+1] = c.G
+		p.pix[offset+2] = c.B
+		p.pix[offset+3] = c.A
+	}
+
+	// Upload the pixels to the existing texture
+	p.canvas.SetPixels(p.pix)
 }
 
 // GetKeypad will get the current keypad
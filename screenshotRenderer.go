@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/Hatch1fy/errors"
+	"github.com/itsmontoya/chip8/vm"
+)
+
+func newScreenshotRenderer(filename string, frames int, scale float64) *screenshotRenderer {
+	var s screenshotRenderer
+	s.HeadlessRenderer = vm.NewHeadlessRenderer()
+	s.filename = filename
+	s.frames = frames
+	s.opts.Scale = int(scale)
+	return &s
+}
+
+// screenshotRenderer is a headless renderer which writes a screenshot and stops the program after a number of frames
+type screenshotRenderer struct {
+	*vm.HeadlessRenderer
+
+	filename string
+	frames   int
+	opts     vm.ImageOptions
+}
+
+// Update will write the screenshot once the number of frames have been run
+func (s *screenshotRenderer) Update() (err error) {
+	if err = s.HeadlessRenderer.Update(); err != nil {
+		return
+	}
+
+	if s.Frames() < s.frames {
+		// Not enough frames have been run, return
+		return
+	}
+
+	g := s.Graphics()
+	if err = g.SavePNG(s.filename, s.opts); err != nil {
+		return
+	}
+
+	out.Successf("Screenshot written to %s", s.filename)
+	// Screenshot has been taken, stop the program
+	return errors.ErrIsClosed
+}
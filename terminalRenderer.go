@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/Hatch1fy/errors"
+	"github.com/itsmontoya/chip8/vm"
+)
+
+const (
+	// ErrInvalidTerminalMode is returned when an unknown terminal drawing mode is provided
+	ErrInvalidTerminalMode = errors.Error("invalid terminal mode, supported modes are halfblock and braille")
+)
+
+const (
+	// terminalHalfBlock draws two pixels per character using the upper half block and colors
+	terminalHalfBlock = "halfblock"
+	// terminalBraille draws eight pixels per character using braille dots
+	terminalBraille = "braille"
+)
+
+const (
+	// DefaultKeyHold is how long a key is considered pressed after it was last received
+	// Terminals do not report key releases, so keys are released once the auto-repeat stops
+	// It is longer than the usual auto-repeat delay (250 - 600ms), so held keys stay pressed until the first repeat arrives
+	DefaultKeyHold = 650 * time.Millisecond
+)
+
+const (
+	// ANSI escape sequences
+	ansiClear      = "\x1b[2J"
+	ansiHome       = "\x1b[H"
+	ansiHideCursor = "\x1b[?25l"
+	ansiShowCursor = "\x1b[?25h"
+	ansiReset      = "\x1b[0m"
+	// Sets the 24 bit foreground and background colors
+	ansiColorFmt = "\x1b[38;2;%d;%d;%d;48;2;%d;%d;%dm"
+)
+
+const (
+	upperHalfBlock = '▀'
+	// brailleBase is the braille character without any dots
+	brailleBase = 0x2800
+	// brailleCellRows is the number of pixel rows in a braille character
+	brailleCellRows = 4
+	// keyCtrlC is received instead of an interrupt signal while the terminal is in raw mode
+	keyCtrlC = 0x03
+)
+
+// brailleDots are the braille bits of each dot in a 2x4 cell, indexed by row and then column
+var brailleDots = [brailleCellRows][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// terminalKeys maps the keyboard to the keypad with the same layout as PixelRenderer
+var terminalKeys = map[byte]int{
+	// 1234
+	'1': 0, '2': 1, '3': 2, '4': 3,
+	// QWER
+	'q': 4, 'w': 5, 'e': 6, 'r': 7,
+	// ASDF
+	'a': 8, 's': 9, 'd': 10, 'f': 11,
+	// ZXCV
+	'z': 12, 'x': 13, 'c': 14, 'v': 15,
+}
+
+func newTerminal(mode string, hold time.Duration) (tp *TerminalRenderer, err error) {
+	var t TerminalRenderer
+	switch mode {
+	case "", terminalHalfBlock:
+		t.braille = false
+	case terminalBraille:
+		t.braille = true
+
+	default:
+		return nil, ErrInvalidTerminalMode
+	}
+
+	t.out = bufio.NewWriter(os.Stdout)
+	t.hold = hold
+	if t.hold <= 0 {
+		t.hold = DefaultKeyHold
+	}
+
+	t.colors = vm.DefaultColors
+
+	// Read keys without waiting for a new line and without echoing them
+	if err = stty("raw", "-echo"); err != nil {
+		return
+	}
+
+	t.out.WriteString(ansiClear + ansiHideCursor)
+	go t.readKeys(os.Stdin)
+
+	tp = &t
+	return
+}
+
+// TerminalRenderer is a renderer which draws to a terminal using ANSI escape sequences
+type TerminalRenderer struct {
+	mux sync.Mutex
+
+	out *bufio.Writer
+	g   vm.Graphics
+	// Whether anything has been drawn yet
+	drawn bool
+
+	braille bool
+	// Colors for each pixel value, XO-CHIP pixels can be set on two planes which results in four colors
+	colors [4]color.RGBA
+
+	// Time each key was last received
+	pressed [16]time.Time
+	hold    time.Duration
+	// Set when Ctrl+C has been pressed
+	closed bool
+}
+
+// Draw will draw the Graphics when they have changed since the last frame
+func (t *TerminalRenderer) Draw(g vm.Graphics) (err error) {
+	if t.drawn && !hasChanged(t.g, g) {
+		// Nothing has changed, no drawing needed
+		return
+	}
+
+	if t.g.Width() != g.Width() || t.g.Height() != g.Height() {
+		// Resolution has changed, clear any larger previous frame
+		t.out.WriteString(ansiClear)
+	}
+
+	t.g.Copy(g)
+	t.drawn = true
+
+	t.out.WriteString(ansiHome)
+	if t.braille {
+		t.drawBraille()
+	} else {
+		t.drawHalfBlocks()
+	}
+
+	return t.out.Flush()
+}
+
+// Update will return ErrIsClosed once Ctrl+C has been pressed, it is called every frame
+func (t *TerminalRenderer) Update() (err error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.closed {
+		// Terminal has been closed, return
+		return errors.ErrIsClosed
+	}
+
+	return
+}
+
+// GetKeypad will get the current keypad, keys are pressed until the hold duration has passed since they were last received
+func (t *TerminalRenderer) GetKeypad() (k vm.Keypad) {
+	return t.keypadAt(time.Now())
+}
+
+// keypadAt will get the keypad at the provided time
+func (t *TerminalRenderer) keypadAt(now time.Time) (k vm.Keypad) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for i, pressedAt := range t.pressed {
+		k.Set(i, now.Sub(pressedAt) < t.hold)
+	}
+
+	return
+}
+
+// Close will restore the terminal
+func (t *TerminalRenderer) Close() (err error) {
+	t.out.WriteString(ansiReset + ansiShowCursor + "\r\n")
+	if err = t.out.Flush(); err != nil {
+		return
+	}
+
+	return stty("-raw", "echo")
+}
+
+// drawHalfBlocks will draw two rows of pixels per line, the upper pixel is the foreground and the lower pixel is the background
+func (t *TerminalRenderer) drawHalfBlocks() {
+	width := t.g.Width()
+	for y := 0; y < t.g.Height(); y += 2 {
+		var lastUpper, lastLower color.RGBA
+		for x := 0; x < width; x++ {
+			upper := t.g.DisplayColor(y*width+x, t.colors)
+			lower := t.colors[0]
+			if y+1 < t.g.Height() {
+				lower = t.g.DisplayColor((y+1)*width+x, t.colors)
+			}
+
+			// Only change colors at the start of the line or when they differ from the previous character
+			if x == 0 || upper != lastUpper || lower != lastLower {
+				fmt.Fprintf(t.out, ansiColorFmt, upper.R, upper.G, upper.B, lower.R, lower.G, lower.B)
+				lastUpper, lastLower = upper, lower
+			}
+
+			t.out.WriteRune(upperHalfBlock)
+		}
+
+		t.out.WriteString(ansiReset + "\r\n")
+	}
+}
+
+// drawBraille will draw 2x4 pixels per character, any set pixel is drawn as a dot
+func (t *TerminalRenderer) drawBraille() {
+	width := t.g.Width()
+	height := t.g.Height()
+	for y := 0; y < height; y += brailleCellRows {
+		for x := 0; x < width; x += 2 {
+			var r rune = brailleBase
+			for row := 0; row < brailleCellRows && y+row < height; row++ {
+				for column := 0; column < 2 && x+column < width; column++ {
+					if t.g.Get((y+row)*width+x+column) != 0 {
+						r |= brailleDots[row][column]
+					}
+				}
+			}
+
+			t.out.WriteRune(r)
+		}
+
+		t.out.WriteString("\r\n")
+	}
+}
+
+// readKeys will read key presses until the reader is closed
+func (t *TerminalRenderer) readKeys(in io.Reader) {
+	r := bufio.NewReader(in)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+
+		t.receiveKey(b, time.Now())
+	}
+}
+
+// receiveKey will press the key of a received byte at the provided time, Ctrl+C closes the terminal
+func (t *TerminalRenderer) receiveKey(b byte, now time.Time) {
+	if b >= 'A' && b <= 'Z' {
+		// Caps lock or shift is held, use the lower case key
+		b += 'a' - 'A'
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if b == keyCtrlC {
+		t.closed = true
+	} else if key, ok := terminalKeys[b]; ok {
+		t.pressed[key] = now
+	}
+}
+
+// hasChanged will return whether the pixels, colors, palette or alpha of the Graphics differ
+func hasChanged(a, b vm.Graphics) bool {
+	if a.Width() != b.Width() || a.Height() != b.Height() || a.IsTrueColor() != b.IsTrueColor() {
+		return true
+	}
+
+	if a.Alpha() != b.Alpha() || !equalColors(a.Palette(), b.Palette()) {
+		// Screen alpha or palette have been changed (MegaChip)
+		return true
+	}
+
+	var changed bool
+	if b.IsTrueColor() {
+		b.ForEachColorDelta(a, func(int, color.RGBA) { changed = true })
+	} else {
+		b.ForEachDelta(a, func(int, byte) { changed = true })
+	}
+
+	return changed
+}
+
+// equalColors will return whether the colors are the same
+func equalColors(a, b []color.RGBA) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// stty will change the settings of the terminal connected to stdin
+func stty(args ...string) (err error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("error setting terminal mode: %v (%s)", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Hatch1fy/errors"
+	"github.com/itsmontoya/chip8/vm"
+)
+
+func TestHasChanged(t *testing.T) {
+	type testcase struct {
+		name     string
+		a        vm.Graphics
+		b        vm.Graphics
+		expected bool
+	}
+
+	set := func(g vm.Graphics, indexes ...int) vm.Graphics {
+		for _, i := range indexes {
+			g.Set(i, 1)
+		}
+
+		return g
+	}
+
+	tcs := []testcase{
+		{name: "blank", a: vm.NewGraphics(4, 2), b: vm.NewGraphics(4, 2), expected: false},
+		{name: "same pixels", a: set(vm.NewGraphics(4, 2), 1, 5), b: set(vm.NewGraphics(4, 2), 1, 5), expected: false},
+		{name: "pixel set", a: vm.NewGraphics(4, 2), b: set(vm.NewGraphics(4, 2), 3), expected: true},
+		{name: "pixel unset", a: set(vm.NewGraphics(4, 2), 3), b: vm.NewGraphics(4, 2), expected: true},
+		{name: "width", a: vm.NewGraphics(4, 2), b: vm.NewGraphics(2, 4), expected: true},
+		{name: "height", a: vm.NewGraphics(4, 2), b: vm.NewGraphics(4, 4), expected: true},
+	}
+
+	// Enable MegaChip mode, set I to 0x20C, load one palette color from it, set the screen alpha and loop
+	program := []byte{0x00, 0x11, 0x01, 0x00, 0x02, 0x0C, 0x02, 0x01, 0x05, 0x80, 0x12, 0x0A, 0xFF, 0xFF, 0x00, 0x00}
+	mega := megaChipGraphics(t, program, 4)
+	tcs = append(tcs,
+		testcase{name: "same MegaChip screen", a: mega[0], b: mega[1], expected: false},
+		testcase{name: "palette", a: mega[1], b: mega[2], expected: true},
+		testcase{name: "alpha", a: mega[2], b: mega[3], expected: true},
+	)
+
+	for _, tc := range tcs {
+		if changed := hasChanged(tc.a, tc.b); changed != tc.expected {
+			t.Fatalf("invalid result for %s, expected %v and received %v", tc.name, tc.expected, changed)
+		}
+	}
+}
+
+func TestTerminalRenderer_drawHalfBlocks(t *testing.T) {
+	type testcase struct {
+		name          string
+		width, height int
+		pixels        []int
+		expected      string
+	}
+
+	white := fmt.Sprintf(ansiColorFmt, 255, 255, 255, 0, 0, 0)
+	black := fmt.Sprintf(ansiColorFmt, 0, 0, 0, 0, 0, 0)
+	lowerWhite := fmt.Sprintf(ansiColorFmt, 0, 0, 0, 255, 255, 255)
+	line := ansiReset + "\r\n"
+
+	tcs := []testcase{
+		{name: "upper pixel", width: 2, height: 2, pixels: []int{0}, expected: white + "▀" + black + "▀" + line},
+		{name: "lower pixel", width: 1, height: 2, pixels: []int{1}, expected: lowerWhite + "▀" + line},
+		{name: "colors are not repeated", width: 2, height: 2, pixels: []int{0, 1}, expected: white + "▀▀" + line},
+		{name: "odd height", width: 1, height: 3, pixels: []int{2}, expected: black + "▀" + line + white + "▀" + line},
+	}
+
+	for _, tc := range tcs {
+		term, buf := newTestTerminal(false)
+		term.g = vm.NewGraphics(tc.width, tc.height)
+		for _, i := range tc.pixels {
+			term.g.Set(i, 1)
+		}
+
+		term.drawHalfBlocks()
+		term.out.Flush()
+		if buf.String() != tc.expected {
+			t.Fatalf("invalid output for %s, expected %q and received %q", tc.name, tc.expected, buf.String())
+		}
+	}
+}
+
+func TestTerminalRenderer_drawBraille(t *testing.T) {
+	type testcase struct {
+		name          string
+		width, height int
+		pixels        []int
+		expected      string
+	}
+
+	tcs := []testcase{
+		{name: "blank", width: 2, height: 4, expected: "⠀\r\n"},
+		{name: "corners", width: 2, height: 4, pixels: []int{0, 7}, expected: "⢁\r\n"},
+		{name: "every dot", width: 2, height: 4, pixels: []int{0, 1, 2, 3, 4, 5, 6, 7}, expected: "⣿\r\n"},
+		{name: "second cell", width: 4, height: 4, pixels: []int{2, 7}, expected: "⠀⠑\r\n"},
+		{name: "partial cell", width: 2, height: 5, pixels: []int{8}, expected: "⠀\r\n⠁\r\n"},
+	}
+
+	for _, tc := range tcs {
+		term, buf := newTestTerminal(true)
+		term.g = vm.NewGraphics(tc.width, tc.height)
+		for _, i := range tc.pixels {
+			term.g.Set(i, 1)
+		}
+
+		term.drawBraille()
+		term.out.Flush()
+		if buf.String() != tc.expected {
+			t.Fatalf("invalid output for %s, expected %q and received %q", tc.name, tc.expected, buf.String())
+		}
+	}
+}
+
+func TestTerminalRenderer_Draw(t *testing.T) {
+	term, buf := newTestTerminal(true)
+	g := vm.NewGraphics(2, 4)
+	if err := term.Draw(g); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := ansiClear + ansiHome + "⠀\r\n"; buf.String() != expected {
+		t.Fatalf("invalid output, expected %q and received %q", expected, buf.String())
+	}
+
+	// Unchanged frames are not drawn again
+	buf.Reset()
+	if err := term.Draw(g); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() != 0 {
+		t.Fatalf("invalid output, expected nothing and received %q", buf.String())
+	}
+
+	g.Set(0, 1)
+	if err := term.Draw(g); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := ansiHome + "⠁\r\n"; buf.String() != expected {
+		t.Fatalf("invalid output, expected %q and received %q", expected, buf.String())
+	}
+}
+
+func TestTerminalRenderer_keys(t *testing.T) {
+	type received struct {
+		b     byte
+		after time.Duration
+	}
+
+	type testcase struct {
+		name     string
+		received []received
+		at       time.Duration
+		expected []int
+	}
+
+	hold := DefaultKeyHold
+	tcs := []testcase{
+		{name: "nothing received", at: 0},
+		{name: "pressed", received: []received{{b: 'w'}}, at: hold / 2, expected: []int{5}},
+		{name: "released after the hold", received: []received{{b: 'w'}}, at: hold},
+		{name: "held by auto-repeat", received: []received{{b: 'w'}, {b: 'w', after: hold - time.Millisecond}}, at: hold + hold/2, expected: []int{5}},
+		{name: "upper case", received: []received{{b: 'V'}}, at: 0, expected: []int{15}},
+		{name: "several keys", received: []received{{b: '1'}, {b: 'z'}}, at: 0, expected: []int{0, 12}},
+		{name: "unknown key", received: []received{{b: 'p'}}, at: 0},
+	}
+
+	start := time.Now()
+	for _, tc := range tcs {
+		term, _ := newTestTerminal(false)
+		for _, r := range tc.received {
+			term.receiveKey(r.b, start.Add(r.after))
+		}
+
+		var expected vm.Keypad
+		for _, key := range tc.expected {
+			expected.Set(key, true)
+		}
+
+		if k := term.keypadAt(start.Add(tc.at)); k != expected {
+			t.Fatalf("invalid keypad for %s, expected %v and received %v", tc.name, expected, k)
+		}
+	}
+
+	// Ctrl+C closes the terminal
+	term, _ := newTestTerminal(false)
+	if err := term.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	term.receiveKey(keyCtrlC, start)
+	if err := term.Update(); err != errors.ErrIsClosed {
+		t.Fatalf("invalid error, expected %v and received %v", errors.ErrIsClosed, err)
+	}
+}
+
+// megaChipGraphics will run a MegaChip program and return a copy of the Graphics after each cycle
+func megaChipGraphics(t *testing.T, program []byte, cycles int) (gs []vm.Graphics) {
+	rom := filepath.Join(t.TempDir(), "mega.ch8")
+	if err := ioutil.WriteFile(rom, program, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var v vm.VM
+	if err := v.SetQuirks(vm.MegaChipQuirks); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+
+	v.Initialize(nil)
+	for i := 0; i < cycles; i++ {
+		if _, err := v.Cycle(); err != nil {
+			t.Fatal(err)
+		}
+
+		var g vm.Graphics
+		g.Copy(v.Graphics())
+		gs = append(gs, g)
+	}
+
+	return
+}
+
+// newTestTerminal will return a TerminalRenderer which writes to a buffer instead of the terminal
+func newTestTerminal(braille bool) (t *TerminalRenderer, buf *bytes.Buffer) {
+	buf = &bytes.Buffer{}
+	t = &TerminalRenderer{}
+	t.out = bufio.NewWriter(buf)
+	t.braille = braille
+	t.hold = DefaultKeyHold
+	t.colors = vm.DefaultColors
+	return
+}
@@ -0,0 +1,317 @@
+package video
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+)
+
+var (
+	// ErrInvalidAVIOptions is returned when an AVI is created without a size or frame rate
+	ErrInvalidAVIOptions = errors.New("invalid AVI options, width, height and frames per second must be at least 1")
+	// ErrNoFrame is returned when a frame is repeated before any frame has been written
+	ErrNoFrame = errors.New("no frame has been written")
+	// ErrInvalidFrameSize is returned when a frame does not have the size of the AVI
+	ErrInvalidFrameSize = errors.New("invalid frame size, frames must have the width and height of the AVI")
+)
+
+const (
+	// avifHasIndex is the main header flag which marks that the file has an idx1 index
+	avifHasIndex = 0x10
+	// aviifKeyframe is the index flag which marks that a chunk can be decoded on its own
+	aviifKeyframe = 0x10
+	// pcmFormat is the WAVE format tag of uncompressed PCM samples
+	pcmFormat = 1
+	// bytesPerSample is the size of each 16 bit mono sample
+	bytesPerSample = 2
+)
+
+const (
+	// Chunk IDs of the video and audio data, prefixed with the stream number
+	videoChunk = "00dc"
+	audioChunk = "01wb"
+)
+
+// AVIOptions are the options used to write an AVI file
+type AVIOptions struct {
+	// Width and Height are the size of every frame in pixels
+	Width  int
+	Height int
+	// FramesPerSecond is the video frame rate
+	FramesPerSecond int
+	// SampleRate is the number of 16 bit mono PCM samples per second, no audio stream is written when zero
+	SampleRate int
+	// Quality is the JPEG quality of the frames (1 - 100), jpeg.DefaultQuality is used when zero
+	Quality int
+}
+
+// NewAVIWriter will return a new AVIWriter which writes MJPEG frames and PCM audio to an AVI file
+// The headers are written again with the final lengths on Close, so w must be seekable
+func NewAVIWriter(w io.WriteSeeker, opts AVIOptions) (ap *AVIWriter, err error) {
+	if opts.Width < 1 || opts.Height < 1 || opts.FramesPerSecond < 1 {
+		return nil, ErrInvalidAVIOptions
+	}
+
+	var a AVIWriter
+	a.w = w
+	a.opts = opts
+	a.jpeg.Quality = opts.Quality
+	if a.jpeg.Quality == 0 {
+		a.jpeg.Quality = jpeg.DefaultQuality
+	}
+
+	// Write the headers with empty lengths, the data follows them
+	if _, err = w.Write(a.header()); err != nil {
+		return
+	}
+
+	ap = &a
+	return
+}
+
+// AVIWriter writes an AVI file with a MJPEG video stream and an optional PCM audio stream
+// Frames and samples are interleaved in the order they are written, players expect one frame of samples per frame
+type AVIWriter struct {
+	w    io.WriteSeeker
+	opts AVIOptions
+	jpeg jpeg.Options
+
+	// Most recently encoded frame, repeated frames are written without being encoded again
+	frame bytes.Buffer
+	// idx1 entries of every chunk
+	index bytes.Buffer
+	// Size of the chunks in the movi list
+	moviSize int
+	// Size of the largest chunk, used by players to allocate buffers
+	maxChunkSize int
+
+	frames  int
+	samples int
+}
+
+// WriteFrame will encode an image as a JPEG and write it as the next frame
+func (a *AVIWriter) WriteFrame(img image.Image) (err error) {
+	if b := img.Bounds(); b.Dx() != a.opts.Width || b.Dy() != a.opts.Height {
+		return ErrInvalidFrameSize
+	}
+
+	a.frame.Reset()
+	if err = jpeg.Encode(&a.frame, img, &a.jpeg); err != nil {
+		return
+	}
+
+	return a.RepeatFrame()
+}
+
+// RepeatFrame will write the previous frame again, for frames which have not changed
+func (a *AVIWriter) RepeatFrame() (err error) {
+	if a.frame.Len() == 0 {
+		return ErrNoFrame
+	}
+
+	if err = a.writeChunk(videoChunk, a.frame.Bytes()); err != nil {
+		return
+	}
+
+	a.frames++
+	return
+}
+
+// WriteSamples will write 16 bit mono PCM samples, it does nothing when the AVI has no audio stream
+func (a *AVIWriter) WriteSamples(samples []int16) (err error) {
+	if a.opts.SampleRate == 0 || len(samples) == 0 {
+		return
+	}
+
+	data := make([]byte, len(samples)*bytesPerSample)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(data[i*bytesPerSample:], uint16(sample))
+	}
+
+	if err = a.writeChunk(audioChunk, data); err != nil {
+		return
+	}
+
+	a.samples += len(samples)
+	return
+}
+
+// Frames will return the number of frames which have been written
+func (a *AVIWriter) Frames() int {
+	return a.frames
+}
+
+// Close will write the index and the headers with the final lengths, w is not closed
+func (a *AVIWriter) Close() (err error) {
+	// Write the index after the movi list
+	if _, err = a.w.Write([]byte("idx1")); err != nil {
+		return
+	}
+
+	if err = binary.Write(a.w, binary.LittleEndian, uint32(a.index.Len())); err != nil {
+		return
+	}
+
+	if _, err = a.w.Write(a.index.Bytes()); err != nil {
+		return
+	}
+
+	// Write the headers again now that the lengths are known
+	if _, err = a.w.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	if _, err = a.w.Write(a.header()); err != nil {
+		return
+	}
+
+	_, err = a.w.Seek(0, io.SeekEnd)
+	return
+}
+
+// writeChunk will write a chunk to the movi list and add it to the index
+func (a *AVIWriter) writeChunk(id string, data []byte) (err error) {
+	var chunk bytes.Buffer
+	chunk.WriteString(id)
+	binary.Write(&chunk, binary.LittleEndian, uint32(len(data)))
+	chunk.Write(data)
+	if len(data)%2 != 0 {
+		// Chunks are aligned to two bytes
+		chunk.WriteByte(0)
+	}
+
+	if _, err = a.w.Write(chunk.Bytes()); err != nil {
+		return
+	}
+
+	// Index offsets are relative to the movi fourcc
+	a.index.WriteString(id)
+	binary.Write(&a.index, binary.LittleEndian, []uint32{aviifKeyframe, uint32(4 + a.moviSize), uint32(len(data))})
+
+	a.moviSize += chunk.Len()
+	if len(data) > a.maxChunkSize {
+		a.maxChunkSize = len(data)
+	}
+
+	return
+}
+
+// header will return the RIFF header, the hdrl list and the start of the movi list with the current lengths
+func (a *AVIWriter) header() []byte {
+	streams := 1
+	if a.opts.SampleRate > 0 {
+		streams++
+	}
+
+	var hdrl riffWriter
+	hdrl.WriteString("hdrl")
+	hdrl.chunk("avih", func(w *riffWriter) {
+		w.uint32s(
+			// Microseconds per frame
+			uint32(1000000/a.opts.FramesPerSecond),
+			// Max bytes per second, padding granularity and flags
+			uint32(a.maxChunkSize*a.opts.FramesPerSecond), 0, avifHasIndex,
+			// Total and initial frames
+			uint32(a.frames), 0,
+			uint32(streams), uint32(a.maxChunkSize),
+			uint32(a.opts.Width), uint32(a.opts.Height),
+			// Reserved
+			0, 0, 0, 0,
+		)
+	})
+
+	hdrl.list("strl", func(w *riffWriter) {
+		w.chunk("strh", func(w *riffWriter) {
+			w.WriteString("vidsMJPG")
+			// Flags, priority and language, initial frames
+			w.uint32s(0, 0, 0)
+			// Scale and rate, which is the frame rate, start, length and buffer size
+			w.uint32s(1, uint32(a.opts.FramesPerSecond), 0, uint32(a.frames), uint32(a.maxChunkSize))
+			// Quality (default) and sample size (varies)
+			w.uint32s(0xFFFFFFFF, 0)
+			w.uint16s(0, 0, uint16(a.opts.Width), uint16(a.opts.Height))
+		})
+
+		w.chunk("strf", func(w *riffWriter) {
+			// BITMAPINFOHEADER
+			w.uint32s(40, uint32(a.opts.Width), uint32(a.opts.Height))
+			// Planes and bits per pixel
+			w.uint16s(1, 24)
+			w.WriteString("MJPG")
+			// Image size, pixels per meter and colors
+			w.uint32s(uint32(a.opts.Width*a.opts.Height*3), 0, 0, 0, 0)
+		})
+	})
+
+	if a.opts.SampleRate > 0 {
+		hdrl.list("strl", func(w *riffWriter) {
+			w.chunk("strh", func(w *riffWriter) {
+				w.WriteString("auds")
+				// Handler, flags, priority and language, initial frames
+				w.uint32s(0, 0, 0, 0)
+				// Scale and rate in bytes per second, start, length in samples and buffer size
+				w.uint32s(bytesPerSample, uint32(a.opts.SampleRate*bytesPerSample), 0, uint32(a.samples), uint32(a.maxChunkSize))
+				// Quality (default) and sample size
+				w.uint32s(0xFFFFFFFF, bytesPerSample)
+				w.uint16s(0, 0, 0, 0)
+			})
+
+			w.chunk("strf", func(w *riffWriter) {
+				// WAVEFORMATEX of 16 bit mono PCM
+				w.uint16s(pcmFormat, 1)
+				w.uint32s(uint32(a.opts.SampleRate), uint32(a.opts.SampleRate*bytesPerSample))
+				w.uint16s(bytesPerSample, 16, 0)
+			})
+		})
+	}
+
+	var riff riffWriter
+	riff.WriteString("RIFF")
+	// Everything after the RIFF size, which is AVI, the hdrl list, the movi list and the index
+	riff.uint32s(uint32(4 + 8 + hdrl.Len() + 12 + a.moviSize + 8 + a.index.Len()))
+	riff.WriteString("AVI ")
+	riff.WriteString("LIST")
+	riff.uint32s(uint32(hdrl.Len()))
+	riff.Write(hdrl.Bytes())
+	riff.WriteString("LIST")
+	riff.uint32s(uint32(4 + a.moviSize))
+	riff.WriteString("movi")
+	return riff.Bytes()
+}
+
+// riffWriter builds RIFF chunks and lists
+type riffWriter struct {
+	bytes.Buffer
+}
+
+// chunk will write a chunk with the data written by fn
+func (r *riffWriter) chunk(id string, fn func(*riffWriter)) {
+	var data riffWriter
+	fn(&data)
+	r.WriteString(id)
+	r.uint32s(uint32(data.Len()))
+	r.Write(data.Bytes())
+	if data.Len()%2 != 0 {
+		// Chunks are aligned to two bytes
+		r.WriteByte(0)
+	}
+}
+
+// list will write a list of the provided type with the chunks written by fn
+func (r *riffWriter) list(listType string, fn func(*riffWriter)) {
+	r.chunk("LIST", func(w *riffWriter) {
+		w.WriteString(listType)
+		fn(w)
+	})
+}
+
+func (r *riffWriter) uint32s(values ...uint32) {
+	binary.Write(r, binary.LittleEndian, values)
+}
+
+func (r *riffWriter) uint16s(values ...uint16) {
+	binary.Write(r, binary.LittleEndian, values)
+}
@@ -0,0 +1,182 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+
+	"github.com/itsmontoya/chip8/vm"
+)
+
+const (
+	// DefaultTailFrames is the number of frames exported after the last input event when Options do not set a length
+	DefaultTailFrames = 2 * vm.FramesPerSecond
+)
+
+// Options are the options used to export a video
+type Options struct {
+	// Image are the options used to convert each frame, the scale sets the size of the video
+	Image vm.ImageOptions
+	// Width and Height are the resolution of the video in Chip8 pixels, 128x64 is used when zero
+	// Frames of other resolutions are scaled to fit and centered, so switching resolutions does not change the video size
+	Width  int
+	Height int
+	// Frames is the number of frames to export, when zero the export stops DefaultTailFrames after the last input event
+	Frames int
+	// Quality is the JPEG quality of the frames (1 - 100), jpeg.DefaultQuality is used when zero
+	Quality int
+	// SampleRate is the number of audio samples per second, vm.DefaultSampleRate is used when zero
+	SampleRate int
+	// Frequency and Volume are the frequency in Hz and the volume (0 - 1) of the buzzer, the vm defaults are used when zero
+	Frequency float64
+	Volume    float64
+}
+
+func (o *Options) getFrames(input vm.InputRecording) int {
+	if o.Frames > 0 {
+		return o.Frames
+	}
+
+	return input.Frames() + DefaultTailFrames
+}
+
+func (o *Options) getSampleRate() int {
+	if o.SampleRate < 1 {
+		return vm.DefaultSampleRate
+	}
+
+	return o.SampleRate
+}
+
+// Export will run a loaded VM headless as fast as possible while replaying the input and write the frames and buzzer audio as an AVI
+// The VM is initialized by Export and its Audio is replaced, its random source is set from the input seed when the seed is not zero
+// The export stops early when the program exits, it returns the number of frames which have been written
+func Export(w io.WriteSeeker, v *vm.VM, input vm.InputRecording, opts Options) (frames int, err error) {
+	width, height := opts.Width, opts.Height
+	if width < 1 || height < 1 {
+		// Use the largest resolution of SUPER-CHIP and XO-CHIP programs
+		width, height = 128, 64
+	}
+
+	scale := opts.Image.Scale
+	if scale < 1 {
+		scale = 1
+	}
+
+	var e exporter
+	e.opts = opts
+	e.img = image.NewRGBA(image.Rect(0, 0, width*scale, height*scale))
+	e.wave = vm.NewSquareWave(opts.getSampleRate(), opts.Frequency, opts.Volume)
+
+	var aopts AVIOptions
+	aopts.Width = e.img.Rect.Dx()
+	aopts.Height = e.img.Rect.Dy()
+	aopts.FramesPerSecond = vm.FramesPerSecond
+	aopts.SampleRate = opts.getSampleRate()
+	aopts.Quality = opts.Quality
+	if e.avi, err = NewAVIWriter(w, aopts); err != nil {
+		return
+	}
+
+	if input.Seed != 0 {
+		// Use the random numbers of the recorded session
+		v.SetRandom(vm.NewRandom(input.Seed))
+	}
+
+	e.r.HeadlessRenderer = vm.NewHeadlessRenderer()
+	e.r.Script(input.Events...)
+	v.Initialize(&e.r)
+	v.SetAudio(&e)
+
+	total := opts.getFrames(input)
+	for e.frame = 0; e.frame < total && !v.Exited(); e.frame++ {
+		if err = v.RunFrame(); err != nil {
+			return e.avi.Frames(), err
+		}
+
+		if err = e.writeFrame(); err != nil {
+			return e.avi.Frames(), err
+		}
+	}
+
+	return e.avi.Frames(), e.avi.Close()
+}
+
+// exporter holds the state of a single export
+type exporter struct {
+	opts Options
+	avi  *AVIWriter
+	r    drawRenderer
+
+	// Frame buffer of the video size
+	img   *image.RGBA
+	frame int
+
+	wave *vm.SquareWave
+	// Audio samples of the frame which has just run
+	samples []int16
+}
+
+// writeFrame will write the video frame and the audio samples of the frame which has just run
+func (e *exporter) writeFrame() (err error) {
+	if e.r.drawn || e.frame == 0 {
+		// Graphics have changed, encode a new frame
+		e.r.drawn = false
+		e.drawImage(e.r.Graphics())
+		if err = e.avi.WriteFrame(e.img); err != nil {
+			return
+		}
+	} else if err = e.avi.RepeatFrame(); err != nil {
+		return
+	}
+
+	return e.avi.WriteSamples(e.samples)
+}
+
+// Play will generate the audio samples of the frame which is running
+func (e *exporter) Play(tone bool) (err error) {
+	e.samples = e.wave.Samples(tone, e.samples[:0])
+	return
+}
+
+// drawImage will draw the Graphics to the frame buffer, scaled as large as they fit and centered
+func (e *exporter) drawImage(g vm.Graphics) {
+	// Clear the frame buffer, parts which are not covered by the Graphics stay black
+	draw.Draw(e.img, e.img.Rect, image.NewUniform(color.RGBA{0, 0, 0, 255}), image.Point{}, draw.Src)
+	if g.Width() == 0 || g.Height() == 0 {
+		return
+	}
+
+	opts := e.opts.Image
+	opts.Scale = e.img.Rect.Dx() / g.Width()
+	if scale := e.img.Rect.Dy() / g.Height(); scale < opts.Scale {
+		opts.Scale = scale
+	}
+
+	if opts.Scale < 1 {
+		// Graphics are larger than the video, draw the top left corner
+		opts.Scale = 1
+	}
+
+	x := (e.img.Rect.Dx() - g.Width()*opts.Scale) / 2
+	y := (e.img.Rect.Dy() - g.Height()*opts.Scale) / 2
+	if x < 0 || y < 0 {
+		x, y = 0, 0
+	}
+
+	g.DrawImage(e.img.SubImage(e.img.Rect.Add(image.Pt(x, y))).(*image.RGBA), opts)
+}
+
+// drawRenderer is a HeadlessRenderer which reports whether the Graphics have been drawn
+type drawRenderer struct {
+	*vm.HeadlessRenderer
+
+	drawn bool
+}
+
+// Draw will store the Graphics and mark them as drawn
+func (d *drawRenderer) Draw(g vm.Graphics) (err error) {
+	d.drawn = true
+	return d.HeadlessRenderer.Draw(g)
+}
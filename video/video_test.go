@@ -0,0 +1,94 @@
+package video
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itsmontoya/chip8/vm"
+)
+
+func TestExport(t *testing.T) {
+	dir := t.TempDir()
+	rom := filepath.Join(dir, "buzz.ch8")
+	// Wait for a key, set the sound timer to 10 frames and loop forever
+	if err := ioutil.WriteFile(rom, []byte{0xF5, 0x0A, 0x6A, 0x0A, 0xFA, 0x18, 0x12, 0x06}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var v vm.VM
+	if err := v.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+
+	var input vm.InputRecording
+	input.Events = []vm.KeyEvent{{Frame: 10, Key: 0x5, Pressed: true}, {Frame: 12, Key: 0x5}}
+
+	f, err := os.Create(filepath.Join(dir, "buzz.avi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var opts Options
+	opts.Image.Scale = 2
+	opts.Frames = 30
+	opts.SampleRate = 6000
+	frames, err := Export(f, &v, input, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if frames != 30 {
+		t.Fatalf("invalid number of frames, expected 30 and received %d", frames)
+	}
+
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data[:4]) != "RIFF" || string(data[8:12]) != "AVI " || int(binary.LittleEndian.Uint32(data[4:])) != len(data)-8 {
+		t.Fatal("invalid RIFF header")
+	}
+
+	// Frames and samples are interleaved, find the audio chunk of every frame
+	var audio [][]byte
+	movi := bytes.Index(data, []byte("movi")) + 4
+	for offset := movi; string(data[offset:offset+4]) != "idx1"; {
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		if string(data[offset:offset+4]) == audioChunk {
+			audio = append(audio, data[offset+8:offset+8+size])
+		}
+
+		offset += 8 + size + size%2
+	}
+
+	if len(audio) != 30 {
+		t.Fatalf("invalid number of audio chunks, expected 30 and received %d", len(audio))
+	}
+
+	// The buzzer only sounds once the key has been released
+	for i, samples := range audio {
+		if len(samples) != 6000/vm.FramesPerSecond*2 {
+			t.Fatalf("invalid number of samples in frame %d, received %d bytes", i, len(samples))
+		}
+
+		if sounding := !isSilent(samples); sounding != (i >= 12 && i < 22) {
+			t.Fatalf("invalid buzzer state in frame %d, received %v", i, sounding)
+		}
+	}
+}
+
+func isSilent(samples []byte) bool {
+	for _, b := range samples {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
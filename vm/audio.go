@@ -0,0 +1,250 @@
+package vm
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+)
+
+const (
+	// DefaultSampleRate is the number of audio samples per second used when a SquareWave does not set one
+	DefaultSampleRate = 44100
+	// DefaultFrequency is the buzzer frequency in Hz used when a SquareWave does not set one
+	DefaultFrequency = 440
+	// DefaultVolume is the buzzer volume used when a SquareWave does not set one, a quarter of the full range
+	DefaultVolume = 0.25
+)
+
+const (
+	// wavHeaderSize is the size of the RIFF header, the fmt chunk and the data chunk header of a WAV file
+	wavHeaderSize = 44
+	// wavPCMFormat is the WAVE format tag of uncompressed PCM samples
+	wavPCMFormat = 1
+	// bytesPerSample is the size of each 16 bit mono sample
+	bytesPerSample = 2
+)
+
+// Audio is a sink for the buzzer, which is driven by the sound timer
+// Play is called once every 60Hz frame before the timers are decremented, tone is on while the sound timer is non-zero
+// MegaChip digitized sound is not played to the Audio, see VM.Sample
+type Audio interface {
+	Play(tone bool) error
+}
+
+// NullAudio is an Audio which does not play anything, it counts the frames for tests
+type NullAudio struct {
+	frames     int
+	toneFrames int
+	tone       bool
+}
+
+// Play will count the frame
+func (n *NullAudio) Play(tone bool) (err error) {
+	n.frames++
+	if tone {
+		n.toneFrames++
+	}
+
+	n.tone = tone
+	return
+}
+
+// Frames will return the number of frames which have been played
+func (n *NullAudio) Frames() int {
+	return n.frames
+}
+
+// ToneFrames will return the number of frames which have been played with the tone on
+func (n *NullAudio) ToneFrames() int {
+	return n.toneFrames
+}
+
+// Tone will return whether the tone was on during the last frame
+func (n *NullAudio) Tone() bool {
+	return n.tone
+}
+
+// NewSquareWave will return a new SquareWave, zero values use the defaults
+func NewSquareWave(sampleRate int, frequency, volume float64) *SquareWave {
+	var s SquareWave
+	s.SampleRate = sampleRate
+	s.Frequency = frequency
+	s.Volume = volume
+	return &s
+}
+
+// SquareWave generates the 16 bit mono samples of the buzzer one 60Hz frame at a time
+type SquareWave struct {
+	// SampleRate is the number of samples per second, DefaultSampleRate is used when zero
+	SampleRate int
+	// Frequency is the frequency of the tone in Hz, DefaultFrequency is used when zero
+	Frequency float64
+	// Volume is the amplitude of the tone from 0 to 1, DefaultVolume is used when zero
+	Volume float64
+
+	// Number of frames which have been generated
+	frame int
+	// Position within the current period, from 0 to 1
+	phase float64
+}
+
+// Samples will append the samples of a single frame, the tone while it is on and silence otherwise
+// Frames have a whole number of samples, they are spread so that the audio stays in sync with the frames
+func (s *SquareWave) Samples(tone bool, samples []int16) []int16 {
+	sampleRate := s.getSampleRate()
+	start := s.frame * sampleRate / FramesPerSecond
+	end := (s.frame + 1) * sampleRate / FramesPerSecond
+	s.frame++
+
+	amplitude := int16(math.Round(s.getVolume() * math.MaxInt16))
+	step := s.getFrequency() / float64(sampleRate)
+	for i := start; i < end; i++ {
+		if !tone {
+			samples = append(samples, 0)
+			continue
+		}
+
+		if s.phase < 0.5 {
+			samples = append(samples, amplitude)
+		} else {
+			samples = append(samples, -amplitude)
+		}
+
+		if s.phase += step; s.phase >= 1 {
+			s.phase -= math.Floor(s.phase)
+		}
+	}
+
+	return samples
+}
+
+func (s *SquareWave) getSampleRate() int {
+	if s.SampleRate < 1 {
+		return DefaultSampleRate
+	}
+
+	return s.SampleRate
+}
+
+func (s *SquareWave) getFrequency() float64 {
+	if s.Frequency <= 0 {
+		return DefaultFrequency
+	}
+
+	return s.Frequency
+}
+
+func (s *SquareWave) getVolume() float64 {
+	switch {
+	case s.Volume <= 0:
+		return DefaultVolume
+	case s.Volume > 1:
+		return 1
+
+	default:
+		return s.Volume
+	}
+}
+
+// NewWAVAudio will return a new WAVAudio which writes the samples of the square wave to w
+// The header is written again with the final length on Close, so w must be seekable
+func NewWAVAudio(w io.WriteSeeker, wave *SquareWave) (ap *WAVAudio, err error) {
+	var a WAVAudio
+	a.w = w
+	a.wave = wave
+	// Write the header with an empty length, the samples follow it
+	if _, err = w.Write(a.header()); err != nil {
+		return
+	}
+
+	ap = &a
+	return
+}
+
+// CreateWAVAudio will create a WAV file and return a new WAVAudio which writes to it, the file is closed on Close
+func CreateWAVAudio(filename string, wave *SquareWave) (ap *WAVAudio, err error) {
+	var f *os.File
+	if f, err = os.Create(filename); err != nil {
+		return
+	}
+
+	if ap, err = NewWAVAudio(f, wave); err != nil {
+		f.Close()
+		return
+	}
+
+	ap.closer = f
+	return
+}
+
+// WAVAudio is an Audio which writes the buzzer as 16 bit mono PCM to a WAV file
+type WAVAudio struct {
+	w      io.WriteSeeker
+	closer io.Closer
+	wave   *SquareWave
+
+	samples []int16
+	buf     []byte
+	// Size of the samples which have been written
+	dataSize int
+}
+
+// Play will write the samples of a single frame
+func (a *WAVAudio) Play(tone bool) (err error) {
+	a.samples = a.wave.Samples(tone, a.samples[:0])
+	a.buf = a.buf[:0]
+	for _, sample := range a.samples {
+		a.buf = append(a.buf, byte(sample), byte(uint16(sample)>>8))
+	}
+
+	if _, err = a.w.Write(a.buf); err != nil {
+		return
+	}
+
+	a.dataSize += len(a.buf)
+	return
+}
+
+// Close will write the header with the final length, the file is closed when it was created by CreateWAVAudio
+func (a *WAVAudio) Close() (err error) {
+	if a.closer != nil {
+		// Close the file even when the header cannot be written, the first error is returned
+		defer func() {
+			if cerr := a.closer.Close(); err == nil {
+				err = cerr
+			}
+		}()
+	}
+
+	if _, err = a.w.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	if _, err = a.w.Write(a.header()); err != nil {
+		return
+	}
+
+	_, err = a.w.Seek(0, io.SeekEnd)
+	return
+}
+
+// header will return the WAV header with the current length
+func (a *WAVAudio) header() []byte {
+	sampleRate := uint32(a.wave.getSampleRate())
+	h := make([]byte, 0, wavHeaderSize)
+	h = append(h, "RIFF"...)
+	h = binary.LittleEndian.AppendUint32(h, uint32(wavHeaderSize-8+a.dataSize))
+	h = append(h, "WAVEfmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 16)
+	// Format, channels, sample rate, bytes per second, block align and bits per sample
+	h = binary.LittleEndian.AppendUint16(h, wavPCMFormat)
+	h = binary.LittleEndian.AppendUint16(h, 1)
+	h = binary.LittleEndian.AppendUint32(h, sampleRate)
+	h = binary.LittleEndian.AppendUint32(h, sampleRate*bytesPerSample)
+	h = binary.LittleEndian.AppendUint16(h, bytesPerSample)
+	h = binary.LittleEndian.AppendUint16(h, 16)
+	h = append(h, "data"...)
+	h = binary.LittleEndian.AppendUint32(h, uint32(a.dataSize))
+	return h
+}
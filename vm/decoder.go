@@ -0,0 +1,350 @@
+package vm
+
+var (
+	// instructions contains the decoded instruction for every opcode
+	instructions = newInstructionTable()
+	// megaChipInstructions contains the decoded 0x0000 - 0x0FFF instructions which only exist in MegaChip mode
+	megaChipInstructions = newMegaChipInstructionTable()
+	// twoPageClear is the 0230 screen clear of the two-page CHIP-8 HIRES interpreter
+	twoPageClear = newInstruction(0x0230, (*VM).op0230)
+)
+
+// handler executes a decoded instruction
+type handler func(v *VM, in instruction) error
+
+// instruction is a decoded opcode, the operands are extracted ahead of time so that handlers do not need to mask the opcode
+type instruction struct {
+	handler handler
+	opcode  opcode
+
+	// X register, bits 8-11
+	x byte
+	// Y register, bits 4-7
+	y byte
+	// 4 bit constant, bits 0-3
+	n byte
+	// 8 bit constant, bits 0-7
+	nn byte
+	// 12 bit address, bits 0-11
+	nnn uint16
+}
+
+// newInstruction will return an instruction for the provided opcode and handler
+func newInstruction(o opcode, h handler) (in instruction) {
+	in.handler = h
+	in.opcode = o
+	in.x = byte(o & 0x0F00 >> 8)
+	in.y = byte(o & 0x00F0 >> 4)
+	in.n = byte(o & 0x000F)
+	in.nn = byte(o & 0x00FF)
+	in.nnn = uint16(o & 0x0FFF)
+	return
+}
+
+func newInstructionTable() (table *[0x10000]instruction) {
+	table = new([0x10000]instruction)
+	for i := range table {
+		o := opcode(i)
+		table[i] = newInstruction(o, decodeHandler(o))
+	}
+
+	return
+}
+
+func newMegaChipInstructionTable() (table *[0x1000]instruction) {
+	table = new([0x1000]instruction)
+	for i := range table {
+		o := opcode(i)
+		// Opcodes which are not MegaChip opcodes are left with a nil handler
+		if h := decodeMegaChip(o); h != nil {
+			table[i] = newInstruction(o, h)
+		}
+	}
+
+	return
+}
+
+// decodeHandler will return the handler for the provided opcode
+func decodeHandler(o opcode) handler {
+	switch o & 0xF000 {
+	case 0x0000:
+		return decode0x0000(o)
+	case 0x1000:
+		return (*VM).op1NNN
+	case 0x2000:
+		return (*VM).op2NNN
+	case 0x3000:
+		return (*VM).op3XNN
+	case 0x4000:
+		return (*VM).op4XNN
+	case 0x5000:
+		return decode0x5000(o)
+	case 0x6000:
+		return (*VM).op6XNN
+	case 0x7000:
+		return (*VM).op7XNN
+	case 0x8000:
+		return decode0x8000(o)
+	case 0x9000:
+		return decode0x9000(o)
+	case 0xA000:
+		return (*VM).opANNN
+	case 0xB000:
+		return (*VM).opBNNN
+	case 0xC000:
+		return (*VM).opCXNN
+	case 0xD000:
+		return (*VM).opDXYN
+	case 0xE000:
+		return decode0xE000(o)
+
+	default:
+		return decode0xF000(o)
+	}
+}
+
+func decode0x0000(o opcode) handler {
+	switch o {
+	case 0x0010:
+		return (*VM).op0010
+	case 0x0011:
+		return (*VM).op0011
+	case 0x00E0:
+		return (*VM).op00E0
+	case 0x00EE:
+		return (*VM).op00EE
+	case 0x00FB:
+		return (*VM).op00FB
+	case 0x00FC:
+		return (*VM).op00FC
+	case 0x00FD:
+		return (*VM).op00FD
+	case 0x00FE:
+		return (*VM).op00FE
+	case 0x00FF:
+		return (*VM).op00FF
+	}
+
+	if o&0xFFF0 == 0x00C0 {
+		return (*VM).op00CN
+	}
+
+	return (*VM).op0NNN
+}
+
+func decode0x5000(o opcode) handler {
+	switch o & 0x000F {
+	case 0x0000:
+		return (*VM).op5XY0
+	case 0x0002:
+		return (*VM).op5XY2
+	case 0x0003:
+		return (*VM).op5XY3
+
+	default:
+		return (*VM).opInvalid
+	}
+}
+
+func decode0x8000(o opcode) handler {
+	switch o & 0x000F {
+	case 0x0000:
+		return (*VM).op8XY0
+	case 0x0001:
+		return (*VM).op8XY1
+	case 0x0002:
+		return (*VM).op8XY2
+	case 0x0003:
+		return (*VM).op8XY3
+	case 0x0004:
+		return (*VM).op8XY4
+	case 0x0005:
+		return (*VM).op8XY5
+	case 0x0006:
+		return (*VM).op8XY6
+	case 0x0007:
+		return (*VM).op8XY7
+	case 0x000E:
+		return (*VM).op8XYE
+
+	default:
+		return (*VM).opInvalid
+	}
+}
+
+func decode0x9000(o opcode) handler {
+	switch o & 0x000F {
+	case 0x0000:
+		return (*VM).op9XY0
+
+	default:
+		return (*VM).opInvalid
+	}
+}
+
+func decode0xE000(o opcode) handler {
+	switch o & 0x00FF {
+	case 0x009E:
+		return (*VM).opEX9E
+	case 0x00A1:
+		return (*VM).opEXA1
+
+	default:
+		return (*VM).opInvalid
+	}
+}
+
+func decode0xF000(o opcode) handler {
+	switch o {
+	case 0xF000:
+		return (*VM).opF000
+	case 0xF002:
+		return (*VM).opF002
+	}
+
+	switch o & 0x00FF {
+	case 0x0001:
+		return (*VM).opFN01
+	case 0x0007:
+		return (*VM).opFX07
+	case 0x000A:
+		return (*VM).opFX0A
+	case 0x0015:
+		return (*VM).opFX15
+	case 0x0018:
+		return (*VM).opFX18
+	case 0x001E:
+		return (*VM).opFX1E
+	case 0x0029:
+		return (*VM).opFX29
+	case 0x0030:
+		return (*VM).opFX30
+	case 0x0033:
+		return (*VM).opFX33
+	case 0x003A:
+		return (*VM).opFX3A
+	case 0x0055:
+		return (*VM).opFX55
+	case 0x0065:
+		return (*VM).opFX65
+	case 0x0075:
+		return (*VM).opFX75
+	case 0x0085:
+		return (*VM).opFX85
+
+	default:
+		return (*VM).opInvalid
+	}
+}
+
+// decodeMegaChip will return the handler for the provided opcode when it only exists in MegaChip mode, otherwise nil
+func decodeMegaChip(o opcode) handler {
+	switch o & 0xFF00 {
+	case 0x0100:
+		return (*VM).op01NN
+	case 0x0200:
+		return (*VM).op02NN
+	case 0x0300:
+		return (*VM).op03NN
+	case 0x0400:
+		return (*VM).op04NN
+	case 0x0500:
+		return (*VM).op05NN
+	case 0x0900:
+		return (*VM).op09NN
+	}
+
+	switch o & 0xFFF0 {
+	case 0x00B0:
+		return (*VM).op00BN
+	case 0x0600:
+		return (*VM).op060N
+	case 0x0800:
+		return (*VM).op080N
+	}
+
+	if o == 0x0700 {
+		return (*VM).op0700
+	}
+
+	return nil
+}
+
+// decode will return the decoded instruction for the provided opcode in the current mode
+func (v *VM) decode(o opcode) *instruction {
+	if o < 0x1000 {
+		if v.twoPage && o == 0x0230 {
+			return &twoPageClear
+		}
+
+		if v.mega.enabled && megaChipInstructions[o].handler != nil {
+			return &megaChipInstructions[o]
+		}
+	}
+
+	return &instructions[o]
+}
+
+// fetch will return the decoded instruction at the program counter
+// Instructions are cached per address until the memory they were decoded from is written to or the mode changes
+func (v *VM) fetch() (in *instruction) {
+	pc := int(v.programCounter)
+	if pc < len(v.decoded) {
+		if in = v.decoded[pc]; in != nil {
+			return
+		}
+	}
+
+	in = v.decode(v.fetchOpcode())
+	if pc < len(v.decoded) {
+		v.decoded[pc] = in
+	}
+
+	return
+}
+
+// store will write a byte to memory and invalidate the instructions decoded from it
+func (v *VM) store(address int, b byte) {
+	v.memory.set(address, b)
+
+	// The byte is the first half of the instruction at address and the second half of the one before it
+	address &= len(v.memory) - 1
+	v.invalidate(address)
+	v.invalidate((address - 1) & (len(v.memory) - 1))
+	v.invalidateBlocks(address)
+	v.markModified(address)
+}
+
+// invalidate will remove the cached instruction at the provided address
+func (v *VM) invalidate(address int) {
+	if address < len(v.decoded) {
+		v.decoded[address] = nil
+	}
+}
+
+// resetDecoded will empty the instruction and block caches, it must be called whenever memory is replaced or the mode changes
+func (v *VM) resetDecoded() {
+	// The program counter is 16 bits, so addresses past XOChipMemorySize are never fetched
+	size := len(v.memory)
+	if size > XOChipMemorySize {
+		size = XOChipMemorySize
+	}
+
+	if len(v.decoded) != size {
+		// Blocks are reallocated to the new size when next compiled
+		v.decoded = make([]*instruction, size)
+		return
+	}
+
+	for i := range v.decoded {
+		v.decoded[i] = nil
+	}
+
+	// Blocks were compiled from the decoded instructions
+	v.resetBlocks()
+}
+
+// Executes an opcode which is not supported by any interpreter.
+func (v *VM) opInvalid(in instruction) (err error) {
+	return v.newOpcodeError(ErrInvalidOpcode, in.opcode)
+}
@@ -0,0 +1,74 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrStackOverflow is returned when a subroutine is called while the stack is full
+	ErrStackOverflow = errors.New("stack overflow")
+	// ErrStackUnderflow is returned when a subroutine returns while the stack is empty
+	ErrStackUnderflow = errors.New("stack underflow")
+	// ErrInvalidStackDepth is returned when a stack depth outside of 1-16 is provided
+	ErrInvalidStackDepth = errors.New("invalid stack depth, must be between 1 and 16")
+	// ErrInvalidMemorySize is returned when a memory size which is not a power of two of at least 4096 bytes is provided
+	ErrInvalidMemorySize = errors.New("invalid memory size, must be a power of two of at least 4096 bytes")
+	// ErrInvalidProgramStart is returned when a program start address outside of memory is provided
+	ErrInvalidProgramStart = errors.New("invalid program start, must be within memory")
+	// ErrProgramTooLarge is returned when a program does not fit in memory
+	ErrProgramTooLarge = errors.New("program is too large to fit in memory")
+	// ErrInvalidOpcode is returned when an opcode is not supported by any interpreter
+	ErrInvalidOpcode = errors.New("invalid opcode")
+	// ErrOpcodeNotImplemented is returned when an opcode is not implemented by the VM
+	ErrOpcodeNotImplemented = errors.New("opcode not implemented")
+	// ErrInvalidInstructionsPerFrame is returned when an instructions per frame value below 1 is provided
+	ErrInvalidInstructionsPerFrame = errors.New("invalid instructions per frame, must be at least 1")
+	// ErrNoRecording is returned when a recording is encoded before any frame has been recorded
+	ErrNoRecording = errors.New("no frames have been recorded")
+	// ErrInvalidInputRecording is returned when an input recording line is not a seed or a key event in frame order
+	ErrInvalidInputRecording = errors.New("invalid input recording, lines must be seed <seed> or <frame> <key> <down|up> in frame order")
+)
+
+// StackError is returned when a stack operation fails
+type StackError struct {
+	// Err is the underlying error (ErrStackOverflow or ErrStackUnderflow)
+	Err error
+
+	// ProgramCounter is the address of the instruction which caused the error
+	ProgramCounter uint16
+	// Opcode is the instruction which caused the error
+	Opcode uint16
+}
+
+// Error will return the error message
+func (s *StackError) Error() string {
+	return fmt.Sprintf("%v at 0x%03X (opcode %04X)", s.Err, s.ProgramCounter, s.Opcode)
+}
+
+// Unwrap will return the underlying error
+func (s *StackError) Unwrap() error {
+	return s.Err
+}
+
+// OpcodeError is returned when an opcode cannot be executed
+// The message is only formatted when Error is called, so returning it does not format any strings
+type OpcodeError struct {
+	// Err is the underlying error (ErrInvalidOpcode or ErrOpcodeNotImplemented)
+	Err error
+
+	// ProgramCounter is the address of the instruction which caused the error
+	ProgramCounter uint16
+	// Opcode is the instruction which caused the error
+	Opcode uint16
+}
+
+// Error will return the error message
+func (o *OpcodeError) Error() string {
+	return fmt.Sprintf("%v at 0x%03X (opcode %04X)", o.Err, o.ProgramCounter, o.Opcode)
+}
+
+// Unwrap will return the underlying error
+func (o *OpcodeError) Unwrap() error {
+	return o.Err
+}
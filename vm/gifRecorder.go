@@ -0,0 +1,261 @@
+package vm
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	// gifDelayUnits is the number of GIF delay units (1/100 s) per second
+	gifDelayUnits = 100
+)
+
+// NewGIFRecorder will return a new GIFRecorder which records the frames drawn to the provided Renderer
+func NewGIFRecorder(r Renderer, opts ImageOptions) *GIFRecorder {
+	var g GIFRecorder
+	g.r = r
+	g.opts = opts
+	return &g
+}
+
+// GIFRecorder is a Renderer which passes every frame to another Renderer and records them as an animated GIF while recording
+// Frames which are not drawn or are drawn without changes extend the previous frame, so every frame is shown for a multiple of 1/60 s
+// Programs which do not draw on the second plane (XO-CHIP) are recorded with two colors, true color Graphics (MegaChip) use the Plan 9 palette
+type GIFRecorder struct {
+	mux sync.Mutex
+
+	r    Renderer
+	opts ImageOptions
+
+	// Most recently drawn Graphics, recorded as the first frame when recording starts
+	g         Graphics
+	recording bool
+
+	frames []gifFrame
+	// Whether any recorded frame has pixels set on the second plane
+	fourColor bool
+}
+
+// gifFrame is a recorded frame at one image pixel per Chip8 pixel
+type gifFrame struct {
+	img *image.Paletted
+	// Number of 60Hz frames the image is shown for
+	duration int
+}
+
+// Draw will draw the Graphics to the wrapped Renderer and record them when they differ from the previous frame
+func (g *GIFRecorder) Draw(gfx Graphics) (err error) {
+	if err = g.r.Draw(gfx); err != nil {
+		return
+	}
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.g.Copy(gfx)
+	if g.recording {
+		g.record()
+	}
+
+	return
+}
+
+// Update will update the wrapped Renderer and extend the current frame by 1/60 s, it is called every frame
+func (g *GIFRecorder) Update() (err error) {
+	if updater, ok := g.r.(Updater); ok {
+		// Update outside of the lock, as the wrapped Renderer may start or stop the recording
+		if err = updater.Update(); err != nil {
+			return
+		}
+	}
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if g.recording && len(g.frames) > 0 {
+		g.frames[len(g.frames)-1].duration++
+	}
+
+	return
+}
+
+// GetKeypad will return the keypad of the wrapped Renderer
+func (g *GIFRecorder) GetKeypad() Keypad {
+	return g.r.GetKeypad()
+}
+
+// Close will close the wrapped Renderer when it needs to be closed
+func (g *GIFRecorder) Close() (err error) {
+	if closer, ok := g.r.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return
+}
+
+// Start will discard any previous recording and start recording from the current frame
+func (g *GIFRecorder) Start() {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.frames = nil
+	g.fourColor = false
+	g.recording = true
+	if g.g.Len() > 0 {
+		// A frame has been drawn already, it is shown until the next one is drawn
+		g.record()
+	}
+}
+
+// Stop will stop recording, the recording is kept until the next Start
+func (g *GIFRecorder) Stop() {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.recording = false
+}
+
+// Recording will return whether frames are currently being recorded
+func (g *GIFRecorder) Recording() bool {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return g.recording
+}
+
+// Encode will encode the recording as an animated GIF, it can be called while recording to encode the frames so far
+func (g *GIFRecorder) Encode(w io.Writer) (err error) {
+	g.mux.Lock()
+	anim, err := g.animation()
+	g.mux.Unlock()
+	if err != nil {
+		return
+	}
+
+	return gif.EncodeAll(w, anim)
+}
+
+// Save will write the recording to an animated GIF file
+func (g *GIFRecorder) Save(filename string) (err error) {
+	var f *os.File
+	if f, err = os.Create(filename); err != nil {
+		return
+	}
+	defer f.Close()
+
+	if err = g.Encode(f); err != nil {
+		return
+	}
+
+	return f.Close()
+}
+
+// record will add the current Graphics as a new frame, unless they are identical to the previous frame
+func (g *GIFRecorder) record() {
+	img := g.paletted()
+	if n := len(g.frames); n > 0 {
+		last := g.frames[n-1].img
+		if last.Rect == img.Rect && len(last.Palette) == len(img.Palette) && bytes.Equal(last.Pix, img.Pix) {
+			// Nothing has changed, the previous frame is shown for longer instead
+			return
+		}
+	}
+
+	g.frames = append(g.frames, gifFrame{img: img})
+}
+
+// paletted will return an image of the current Graphics with one image pixel per Chip8 pixel
+func (g *GIFRecorder) paletted() (img *image.Paletted) {
+	colors := g.opts.getColors()
+	img = image.NewPaletted(image.Rect(0, 0, g.g.width, g.g.height), color.Palette{colors[0], colors[1], colors[2], colors[3]})
+	if g.g.colors == nil {
+		for i, pixel := range g.g.pixels {
+			img.Pix[i] = pixel & allPlanes
+			if img.Pix[i] > 1 {
+				g.fourColor = true
+			}
+		}
+
+		return
+	}
+
+	// True color Graphics use the closest color of the Plan 9 palette, most frames only use a few colors
+	img.Palette = palette.Plan9
+	indexes := make(map[color.RGBA]byte)
+	for i := range g.g.pixels {
+		c := g.g.DisplayColor(i, colors)
+		index, ok := indexes[c]
+		if !ok {
+			index = byte(img.Palette.Index(c))
+			indexes[c] = index
+		}
+
+		img.Pix[i] = index
+	}
+
+	return
+}
+
+// animation will return the recorded frames scaled to a single size with their delays
+func (g *GIFRecorder) animation() (anim *gif.GIF, err error) {
+	if len(g.frames) == 0 {
+		return nil, ErrNoRecording
+	}
+
+	// Frames of every resolution are scaled to the largest one
+	var width, height int
+	for _, f := range g.frames {
+		if f.img.Rect.Dx() > width {
+			width = f.img.Rect.Dx()
+		}
+
+		if f.img.Rect.Dy() > height {
+			height = f.img.Rect.Dy()
+		}
+	}
+
+	scale := g.opts.getScale()
+	anim = &gif.GIF{}
+	anim.Config.Width = width * scale
+	anim.Config.Height = height * scale
+
+	var frames, shown int
+	for _, f := range g.frames {
+		// Every frame is shown for at least 1/60 s, including the last frame when recording has just started
+		if f.duration > 0 {
+			frames += f.duration
+		} else {
+			frames++
+		}
+
+		// Delays are rounded from the total time so far, which keeps 1/60 s frames from drifting
+		end := (frames*gifDelayUnits + FramesPerSecond/2) / FramesPerSecond
+		anim.Image = append(anim.Image, g.scale(f.img, anim.Config.Width, anim.Config.Height))
+		anim.Delay = append(anim.Delay, end-shown)
+		shown = end
+	}
+
+	return
+}
+
+// scale will return the image scaled to the provided size
+func (g *GIFRecorder) scale(src *image.Paletted, width, height int) (img *image.Paletted) {
+	pal := src.Palette
+	if len(pal) == 4 && !g.fourColor {
+		// The second plane is never used, the two color palette is smaller to encode
+		pal = pal[:2]
+	}
+
+	img = image.NewPaletted(image.Rect(0, 0, width, height), pal)
+	srcWidth := src.Rect.Dx()
+	srcHeight := src.Rect.Dy()
+	for y := 0; y < height; y++ {
+		row := src.Pix[(y*srcHeight/height)*src.Stride:]
+		for x := 0; x < width; x++ {
+			img.Pix[y*img.Stride+x] = row[x*srcWidth/width]
+		}
+	}
+
+	return
+}
@@ -1,12 +1,122 @@
 package vm
 
+import "image/color"
+
+const (
+	graphicsWidth  = 64
+	graphicsHeight = 32
+
+	twoPageGraphicsHeight = 64
+
+	hiresGraphicsWidth  = 128
+	hiresGraphicsHeight = 64
+
+	megaGraphicsWidth  = 256
+	megaGraphicsHeight = 192
+)
+
+// allPlanes is the mask of every XO-CHIP bit plane, pixel values are a combination of the planes they are set on
+const allPlanes = 0x03
+
+// NewGraphics will return blank Graphics of the provided resolution
+func NewGraphics(width, height int) Graphics {
+	return newGraphics(width, height)
+}
+
+func newGraphics(width, height int) (g Graphics) {
+	g.width = width
+	g.height = height
+	g.pixels = make([]byte, width*height)
+	return
+}
+
+// newTrueColorGraphics will return Graphics which store a color for every pixel alongside its palette index (MegaChip)
+func newTrueColorGraphics(width, height int) (g Graphics) {
+	g = newGraphics(width, height)
+	g.colors = make([]color.RGBA, width*height)
+	g.palette = make([]color.RGBA, 256)
+	g.alpha = 0xFF
+	return
+}
+
 // Graphics represents the system graphics
-type Graphics [64 * 32]byte
+type Graphics struct {
+	width  int
+	height int
+
+	pixels []byte
+
+	// Per-pixel colors and the palette they were drawn from, only set for true color Graphics (MegaChip)
+	colors  []color.RGBA
+	palette []color.RGBA
+	// Screen alpha, applied to every pixel by the renderer
+	alpha byte
+}
+
+// Width will return the width of the screen in pixels
+func (g *Graphics) Width() int {
+	return g.width
+}
+
+// Height will return the height of the screen in pixels
+func (g *Graphics) Height() int {
+	return g.height
+}
+
+// Get will return the value of the pixel at the provided index
+// The value is 0 or 1, or a combination of bit planes (0-3) for XO-CHIP programs
+// For true color Graphics, the value is the palette index the pixel was last drawn with
+func (g *Graphics) Get(index int) byte {
+	return g.pixels[index]
+}
+
+// Set will set the value of the pixel at the provided index
+func (g *Graphics) Set(index int, val byte) {
+	g.pixels[index] = val
+}
+
+// Len will return the number of pixels on the screen
+func (g *Graphics) Len() int {
+	return len(g.pixels)
+}
+
+// IsTrueColor will return whether or not every pixel has its own color (MegaChip)
+// Renderers should use ColorAt or DisplayColor instead of mapping Get values to their own colors when this is true
+func (g *Graphics) IsTrueColor() bool {
+	return g.colors != nil
+}
+
+// ColorAt will return the color of the pixel at the provided index for true color Graphics
+func (g *Graphics) ColorAt(index int) color.RGBA {
+	return g.colors[index]
+}
+
+// Palette will return the palette loaded by the program for true color Graphics
+func (g *Graphics) Palette() []color.RGBA {
+	return g.palette
+}
+
+// Alpha will return the screen alpha for true color Graphics, 0xFF is fully opaque
+func (g *Graphics) Alpha() byte {
+	return g.alpha
+}
+
+// Copy will set the Graphics to a copy of the provided Graphics, the existing buffers are reused when possible
+func (g *Graphics) Copy(in Graphics) {
+	g.width = in.width
+	g.height = in.height
+	g.alpha = in.alpha
+	g.pixels = copyBytes(g.pixels, in.pixels)
+	g.colors = copyColors(g.colors, in.colors)
+	g.palette = copyColors(g.palette, in.palette)
+}
 
 // ForEachDelta will iterate over all the pixels which changed since the last frame
+// When the resolution has changed, every pixel is considered to have changed
 func (g *Graphics) ForEachDelta(in Graphics, fn func(index int, val byte)) {
-	for i, val := range g {
-		if val == in[i] {
+	isSameSize := g.width == in.width && g.height == in.height
+	for i, val := range g.pixels {
+		if isSameSize && val == in.pixels[i] {
 			// Values are the same, no drawing needed
 			continue
 		}
@@ -15,12 +125,156 @@ func (g *Graphics) ForEachDelta(in Graphics, fn func(index int, val byte)) {
 	}
 }
 
+// ForEachColorDelta will iterate over all the pixels of true color Graphics whose color changed since the last frame
+// When the resolution has changed, every pixel is considered to have changed
+func (g *Graphics) ForEachColorDelta(in Graphics, fn func(index int, c color.RGBA)) {
+	isSameSize := g.width == in.width && g.height == in.height && in.colors != nil
+	for i, c := range g.colors {
+		if isSameSize && c == in.colors[i] {
+			// Colors are the same, no drawing needed
+			continue
+		}
+
+		fn(i, c)
+	}
+}
+
 func (g *Graphics) setAllTo(val byte) {
-	for i := range g {
-		g[i] = val
+	for i := range g.pixels {
+		g.pixels[i] = val
 	}
 }
 
 func (g *Graphics) clear() {
 	g.setAllTo(0)
+	for i := range g.colors {
+		g.colors[i] = color.RGBA{}
+	}
+}
+
+// clearPlanes will unset the provided planes for every pixel
+func (g *Graphics) clearPlanes(planes byte) {
+	if g.IsTrueColor() {
+		// True color Graphics have no planes
+		g.clear()
+		return
+	}
+
+	for i := range g.pixels {
+		g.pixels[i] &^= planes
+	}
+}
+
+// moveTo will replace the provided planes of the pixel at index dst with those of the pixel at index src
+// For true color Graphics the whole pixel is moved
+func (g *Graphics) moveTo(dst, src int, planes byte) {
+	if g.IsTrueColor() {
+		g.pixels[dst] = g.pixels[src]
+		g.colors[dst] = g.colors[src]
+		return
+	}
+
+	g.pixels[dst] = (g.pixels[dst] &^ planes) | (g.pixels[src] & planes)
+}
+
+// unset will unset the provided planes of the pixel at the provided index
+// For true color Graphics the whole pixel is unset
+func (g *Graphics) unset(index int, planes byte) {
+	if g.IsTrueColor() {
+		g.pixels[index] = 0
+		g.colors[index] = color.RGBA{}
+		return
+	}
+
+	g.pixels[index] &^= planes
+}
+
+// scrollDown will move the provided planes down by n rows, rows scrolled in from the top are blank
+func (g *Graphics) scrollDown(n int, planes byte) {
+	if n > g.height {
+		n = g.height
+	}
+
+	offset := n * g.width
+	// Iterate backwards so that source pixels are read before they are overwritten
+	for i := len(g.pixels) - 1; i >= offset; i-- {
+		g.moveTo(i, i-offset, planes)
+	}
+
+	for i := 0; i < offset; i++ {
+		g.unset(i, planes)
+	}
+}
+
+// scrollUp will move the provided planes up by n rows, rows scrolled in from the bottom are blank
+func (g *Graphics) scrollUp(n int, planes byte) {
+	if n > g.height {
+		n = g.height
+	}
+
+	offset := n * g.width
+	for i := 0; i < len(g.pixels)-offset; i++ {
+		g.moveTo(i, i+offset, planes)
+	}
+
+	for i := len(g.pixels) - offset; i < len(g.pixels); i++ {
+		g.unset(i, planes)
+	}
+}
+
+// scrollRight will move the provided planes right by n columns, columns scrolled in from the left are blank
+func (g *Graphics) scrollRight(n int, planes byte) {
+	for y := 0; y < g.height; y++ {
+		row := y * g.width
+		// Iterate backwards so that source pixels are read before they are overwritten
+		for x := g.width - 1; x >= n; x-- {
+			g.moveTo(row+x, row+x-n, planes)
+		}
+
+		for x := 0; x < n; x++ {
+			g.unset(row+x, planes)
+		}
+	}
+}
+
+// scrollLeft will move the provided planes left by n columns, columns scrolled in from the right are blank
+func (g *Graphics) scrollLeft(n int, planes byte) {
+	for y := 0; y < g.height; y++ {
+		row := y * g.width
+		for x := 0; x < g.width-n; x++ {
+			g.moveTo(row+x, row+x+n, planes)
+		}
+
+		for x := g.width - n; x < g.width; x++ {
+			g.unset(row+x, planes)
+		}
+	}
+}
+
+func copyBytes(dst, src []byte) []byte {
+	if src == nil {
+		return nil
+	}
+
+	if cap(dst) < len(src) {
+		dst = make([]byte, len(src))
+	}
+
+	dst = dst[:len(src)]
+	copy(dst, src)
+	return dst
+}
+
+func copyColors(dst, src []color.RGBA) []color.RGBA {
+	if src == nil {
+		return nil
+	}
+
+	if cap(dst) < len(src) {
+		dst = make([]color.RGBA, len(src))
+	}
+
+	dst = dst[:len(src)]
+	copy(dst, src)
+	return dst
 }
@@ -0,0 +1,104 @@
+package vm
+
+import "sync"
+
+// NewHeadlessRenderer will return a new HeadlessRenderer
+func NewHeadlessRenderer() *HeadlessRenderer {
+	var h HeadlessRenderer
+	return &h
+}
+
+// HeadlessRenderer is a Renderer which does not display anything, it is used for tests and servers
+// The latest Graphics are stored and frames are counted, Draw and Update never block
+// Keypad input can be set directly or scripted to change at specific frames
+type HeadlessRenderer struct {
+	mux sync.RWMutex
+
+	g      Graphics
+	frames int
+
+	keypad Keypad
+	// Scripted key events which have not been applied yet
+	script []KeyEvent
+}
+
+// KeyEvent is a scripted change of a key state
+type KeyEvent struct {
+	// Frame is the number of frames which must have been run before the event is applied
+	Frame int
+	// Key is the keypad index (0x0 - 0xF)
+	Key byte
+	// Pressed is the new state of the key
+	Pressed bool
+}
+
+// Draw will store a copy of the Graphics
+func (h *HeadlessRenderer) Draw(g Graphics) (err error) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.g.Copy(g)
+	return
+}
+
+// Update will count the frame and apply the scripted key events which are due
+func (h *HeadlessRenderer) Update() (err error) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.frames++
+	h.applyScript()
+	return
+}
+
+// GetKeypad will return the current keypad state
+func (h *HeadlessRenderer) GetKeypad() Keypad {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	return h.keypad
+}
+
+// Graphics will return a copy of the most recently drawn Graphics
+func (h *HeadlessRenderer) Graphics() (g Graphics) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	g.Copy(h.g)
+	return
+}
+
+// Frames will return the number of frames which have been run
+func (h *HeadlessRenderer) Frames() int {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	return h.frames
+}
+
+// SetKey will set the state of a single key
+func (h *HeadlessRenderer) SetKey(key byte, pressed bool) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.keypad.Set(int(key&0x0F), pressed)
+}
+
+// SetKeypad will set the state of every key
+func (h *HeadlessRenderer) SetKeypad(k Keypad) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.keypad = k
+}
+
+// Script will add key events to apply once their frame has been run, events must be in frame order
+// Events for frames which have already been run are applied immediately
+func (h *HeadlessRenderer) Script(events ...KeyEvent) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.script = append(h.script, events...)
+	h.applyScript()
+}
+
+// applyScript will apply the scripted key events which are due
+func (h *HeadlessRenderer) applyScript() {
+	for len(h.script) > 0 && h.script[0].Frame <= h.frames {
+		e := h.script[0]
+		h.keypad.Set(int(e.Key&0x0F), e.Pressed)
+		h.script = h.script[1:]
+	}
+}
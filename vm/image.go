@@ -0,0 +1,117 @@
+package vm
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+)
+
+// DefaultColors are the colors used for each pixel value when ImageOptions do not set any
+var DefaultColors = [4]color.RGBA{
+	// Unset pixel
+	{0, 0, 0, 255},
+	// Pixel set on the first plane
+	{255, 255, 255, 255},
+	// Pixel set on the second plane (XO-CHIP)
+	{255, 102, 0, 255},
+	// Pixel set on both planes (XO-CHIP)
+	{102, 34, 0, 255},
+}
+
+// ImageOptions are the options used to convert Graphics into images
+type ImageOptions struct {
+	// Scale is the width and height in image pixels of every Chip8 pixel, 1 is used when zero
+	Scale int
+	// Colors are the colors of each pixel value, 0 is off and 1 is on
+	// 2 and 3 are only used by XO-CHIP programs which draw on the second plane
+	// DefaultColors are used when every color is zero, true color Graphics (MegaChip) use their own colors
+	Colors [4]color.RGBA
+}
+
+func (o *ImageOptions) getScale() int {
+	if o.Scale < 1 {
+		return 1
+	}
+
+	return o.Scale
+}
+
+func (o *ImageOptions) getColors() [4]color.RGBA {
+	if o.Colors == [4]color.RGBA{} {
+		return DefaultColors
+	}
+
+	return o.Colors
+}
+
+// Image will return a new image of the Graphics
+func (g *Graphics) Image(opts ImageOptions) (img *image.RGBA) {
+	scale := opts.getScale()
+	img = image.NewRGBA(image.Rect(0, 0, g.width*scale, g.height*scale))
+	g.DrawImage(img, opts)
+	return
+}
+
+// WritePNG will encode an image of the Graphics as a PNG
+func (g *Graphics) WritePNG(w io.Writer, opts ImageOptions) (err error) {
+	return png.Encode(w, g.Image(opts))
+}
+
+// SavePNG will write an image of the Graphics to a PNG file
+func (g *Graphics) SavePNG(filename string, opts ImageOptions) (err error) {
+	var f *os.File
+	if f, err = os.Create(filename); err != nil {
+		return
+	}
+	defer f.Close()
+
+	if err = g.WritePNG(f, opts); err != nil {
+		return
+	}
+
+	return f.Close()
+}
+
+// DrawImage will draw the Graphics to the top left corner of an existing image
+// The image should be at least the Graphics width and height multiplied by the scale, pixels outside of it are not drawn
+func (g *Graphics) DrawImage(img *image.RGBA, opts ImageOptions) {
+	scale := opts.getScale()
+	colors := opts.getColors()
+	bounds := img.Bounds()
+	for i := range g.pixels {
+		c := g.DisplayColor(i, colors)
+		x := (i % g.width) * scale
+		y := (i / g.width) * scale
+		for dy := 0; dy < scale && bounds.Min.Y+y+dy < bounds.Max.Y; dy++ {
+			row := img.PixOffset(bounds.Min.X+x, bounds.Min.Y+y+dy)
+			for dx := 0; dx < scale && bounds.Min.X+x+dx < bounds.Max.X; dx++ {
+				offset := row + dx*4
+				img.Pix[offset+0] = c.R
+				img.Pix[offset+1] = c.G
+				img.Pix[offset+2] = c.B
+				img.Pix[offset+3] = c.A
+			}
+		}
+	}
+}
+
+// DisplayColor will return the color the pixel at the provided index is displayed with
+// Pixel values are mapped to the provided colors (see DefaultColors), true color Graphics (MegaChip) use their own colors with the screen alpha applied
+// The returned color is alpha-premultiplied, renderers use it so that every output shows the same colors
+func (g *Graphics) DisplayColor(index int, colors [4]color.RGBA) color.RGBA {
+	if g.colors == nil {
+		// Use the color of the planes the pixel is set on
+		return colors[g.pixels[index]&allPlanes]
+	}
+
+	// Apply the screen alpha, colors are alpha-premultiplied so every channel is scaled
+	c := g.colors[index]
+	alpha := uint16(g.alpha)
+	c.R = byte(uint16(c.R) * alpha / 0xFF)
+	c.G = byte(uint16(c.G) * alpha / 0xFF)
+	c.B = byte(uint16(c.B) * alpha / 0xFF)
+	c.A = byte(uint16(c.A) * alpha / 0xFF)
+	return c
+}
@@ -0,0 +1,32 @@
+package vm
+
+import "image"
+
+// NewImageRenderer will return a new ImageRenderer which calls onFrame with every drawn frame
+func NewImageRenderer(opts ImageOptions, onFrame func(*image.RGBA)) *ImageRenderer {
+	var i ImageRenderer
+	i.HeadlessRenderer = NewHeadlessRenderer()
+	i.opts = opts
+	i.onFrame = onFrame
+	return &i
+}
+
+// ImageRenderer is a Renderer which converts every drawn frame into an image
+// It is used for screenshots, video export and streaming, keypad input is set like it is for HeadlessRenderer
+type ImageRenderer struct {
+	*HeadlessRenderer
+
+	opts    ImageOptions
+	onFrame func(*image.RGBA)
+}
+
+// Draw will convert the Graphics into a new image and pass it to the frame callback
+// A new image is created for every frame, so the callback can keep it
+func (i *ImageRenderer) Draw(g Graphics) (err error) {
+	if err = i.HeadlessRenderer.Draw(g); err != nil {
+		return
+	}
+
+	i.onFrame(g.Image(i.opts))
+	return
+}
@@ -0,0 +1,225 @@
+package vm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// inputRecordingHeader is the comment written at the start of input recordings
+	inputRecordingHeader = "# chip8 input recording: seed <seed>, then <frame> <key> <down|up> for every key change"
+)
+
+// InputRecording is a recorded session of keypad input
+// It is replayed by scripting the events on a HeadlessRenderer, Seed must be used as the random source for the replay to match
+//
+// The text format has one entry per line, blank lines and lines starting with # are ignored:
+//
+//	seed 1602932000
+//	120 5 down
+//	134 5 up
+type InputRecording struct {
+	// Seed is the seed of the random source the session was run with, zero when unknown
+	Seed int64
+	// Events are the key changes in frame order
+	Events []KeyEvent
+}
+
+// ReadInputRecording will read an input recording in the text format
+func ReadInputRecording(r io.Reader) (rec InputRecording, err error) {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			// Blank line or comment, skip
+			continue
+		}
+
+		if err = rec.parse(fields); err != nil {
+			err = fmt.Errorf("%w on line %d", err, line)
+			return
+		}
+	}
+
+	err = scanner.Err()
+	return
+}
+
+// LoadInputRecording will read an input recording from a file
+func LoadInputRecording(filename string) (rec InputRecording, err error) {
+	var f *os.File
+	if f, err = os.Open(filename); err != nil {
+		return
+	}
+	defer f.Close()
+
+	return ReadInputRecording(f)
+}
+
+// Write will write the input recording in the text format
+func (i *InputRecording) Write(w io.Writer) (err error) {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, inputRecordingHeader)
+	fmt.Fprintf(bw, "seed %d\n", i.Seed)
+	for _, e := range i.Events {
+		state := "up"
+		if e.Pressed {
+			state = "down"
+		}
+
+		fmt.Fprintf(bw, "%d %X %s\n", e.Frame, e.Key&0x0F, state)
+	}
+
+	return bw.Flush()
+}
+
+// Save will write the input recording to a file
+func (i *InputRecording) Save(filename string) (err error) {
+	var f *os.File
+	if f, err = os.Create(filename); err != nil {
+		return
+	}
+	defer f.Close()
+
+	if err = i.Write(f); err != nil {
+		return
+	}
+
+	return f.Close()
+}
+
+// Frames will return the frame of the last event
+func (i *InputRecording) Frames() int {
+	if len(i.Events) == 0 {
+		return 0
+	}
+
+	return i.Events[len(i.Events)-1].Frame
+}
+
+// parse will add the entry of a single line
+func (i *InputRecording) parse(fields []string) (err error) {
+	if fields[0] == "seed" {
+		if len(fields) != 2 {
+			return ErrInvalidInputRecording
+		}
+
+		if i.Seed, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return ErrInvalidInputRecording
+		}
+
+		return
+	}
+
+	if len(fields) != 3 {
+		return ErrInvalidInputRecording
+	}
+
+	var e KeyEvent
+	if e.Frame, err = strconv.Atoi(fields[0]); err != nil || e.Frame < 0 {
+		return ErrInvalidInputRecording
+	}
+
+	var key uint64
+	if key, err = strconv.ParseUint(fields[1], 16, 4); err != nil {
+		return ErrInvalidInputRecording
+	}
+
+	e.Key = byte(key)
+	switch fields[2] {
+	case "down":
+		e.Pressed = true
+	case "up":
+		e.Pressed = false
+
+	default:
+		return ErrInvalidInputRecording
+	}
+
+	if n := len(i.Events); n > 0 && i.Events[n-1].Frame > e.Frame {
+		// Events must be in frame order to be scripted
+		return ErrInvalidInputRecording
+	}
+
+	i.Events = append(i.Events, e)
+	return
+}
+
+// NewInputRecorder will return a new InputRecorder which records the keypad of the provided Renderer
+// The seed is stored in the recording, it should be the seed of the random source the VM runs with
+func NewInputRecorder(r Renderer, seed int64) *InputRecorder {
+	var i InputRecorder
+	i.r = r
+	i.rec.Seed = seed
+	return &i
+}
+
+// InputRecorder is a Renderer which passes every frame to another Renderer and records the changes of its keypad
+// Frames are counted the same way as HeadlessRenderer counts them, so scripting the recorded events replays the session
+type InputRecorder struct {
+	mux sync.Mutex
+
+	r Renderer
+
+	frames int
+	keypad Keypad
+	rec    InputRecording
+}
+
+// Draw will draw the Graphics to the wrapped Renderer
+func (i *InputRecorder) Draw(g Graphics) (err error) {
+	return i.r.Draw(g)
+}
+
+// Update will update the wrapped Renderer and count the frame, it is called every frame
+func (i *InputRecorder) Update() (err error) {
+	if updater, ok := i.r.(Updater); ok {
+		if err = updater.Update(); err != nil {
+			return
+		}
+	}
+
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	i.frames++
+	return
+}
+
+// GetKeypad will return the keypad of the wrapped Renderer and record the keys which have changed
+func (i *InputRecorder) GetKeypad() (k Keypad) {
+	k = i.r.GetKeypad()
+
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	for key := 0; key < 16; key++ {
+		if k[key] != i.keypad[key] {
+			i.rec.Events = append(i.rec.Events, KeyEvent{Frame: i.frames, Key: byte(key), Pressed: k[key] != 0})
+		}
+	}
+
+	i.keypad = k
+	return
+}
+
+// Close will close the wrapped Renderer when it needs to be closed
+func (i *InputRecorder) Close() (err error) {
+	if closer, ok := i.r.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return
+}
+
+// Recording will return a copy of the input recorded so far
+func (i *InputRecorder) Recording() (rec InputRecording) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	rec.Seed = i.rec.Seed
+	rec.Events = append([]KeyEvent(nil), i.rec.Events...)
+	return
+}
@@ -0,0 +1,279 @@
+package vm
+
+import "image/color"
+
+const (
+	// MegaChipMemorySize is the memory size of MegaChip, addressable with its 24 bit I register
+	MegaChipMemorySize = 1 << 24
+)
+
+const (
+	blendNormal blendMode = iota
+	blend25
+	blend50
+	blend75
+	blendAdditive
+	blendMultiply
+)
+
+// blendMode is how MegaChip sprite colors are combined with the colors already on the screen
+type blendMode byte
+
+// megaChip is the state of the MegaChip platform
+type megaChip struct {
+	// Set by 0011, the 256x192 true color display and the MegaChip opcodes are enabled
+	enabled bool
+
+	spriteWidth    int
+	spriteHeight   int
+	blendMode      blendMode
+	collisionColor byte
+
+	// Currently playing digitized sound
+	sample *Sample
+}
+
+// Sample is a digitized sound played by a MegaChip program
+type Sample struct {
+	// Rate is the sample rate in Hz
+	Rate int
+	// Data are unsigned 8 bit mono samples
+	Data []byte
+	// Loop is true when the sample repeats until it is stopped
+	Loop bool
+}
+
+// Sample will return the digitized sound which is currently playing, or nil when none is playing (MegaChip)
+// The VM does not play digitized sound, frontends which support it are expected to read the sample every frame
+func (v *VM) Sample() *Sample {
+	return v.mega.sample
+}
+
+// leaveMegaChip will disable MegaChip mode, it is called when the screen is replaced by one without colors
+func (v *VM) leaveMegaChip() {
+	if !v.mega.enabled {
+		return
+	}
+
+	v.mega.enabled = false
+	// Opcodes decode differently in MegaChip mode
+	v.resetDecoded()
+}
+
+// Disables MegaChip mode, the screen becomes 64x32 and is cleared. (MegaChip)
+func (v *VM) op0010(in instruction) (err error) {
+	v.leaveMegaChip()
+	v.graphics = newGraphics(graphicsWidth, graphicsHeight)
+	v.needsDraw = true
+	v.programCounter += 2
+	return
+}
+
+// Enables MegaChip mode, the screen becomes 256x192 with a color for every pixel and is cleared. (MegaChip)
+func (v *VM) op0011(in instruction) (err error) {
+	v.mega.enabled = true
+	v.graphics = newTrueColorGraphics(megaGraphicsWidth, megaGraphicsHeight)
+	// Opcodes decode differently in MegaChip mode
+	v.resetDecoded()
+	v.needsDraw = true
+	v.programCounter += 2
+	return
+}
+
+// Sets I to the 24 bit address made of NN and the next two bytes, the instruction is four bytes long. (MegaChip)
+func (v *VM) op01NN(in instruction) (err error) {
+	next := uint32(v.memory.get(int(v.programCounter)+2))<<8 | uint32(v.memory.get(int(v.programCounter)+3))
+	v.indexRegister = uint32(in.nn)<<16 | next
+
+	// Increment program counter by 4
+	v.programCounter += 4
+	return
+}
+
+// Loads NN colors starting at I into the palette, starting at index 1. Each color is stored as four ARGB bytes. (MegaChip)
+func (v *VM) op02NN(in instruction) (err error) {
+	if !v.mega.enabled {
+		// The palette only exists on the MegaChip true color screen
+		return v.newOpcodeError(ErrInvalidOpcode, in.opcode)
+	}
+
+	palette := v.graphics.palette
+	count := int(in.nn)
+	for i := 0; i < count && i+1 < len(palette); i++ {
+		address := int(v.indexRegister) + i*4
+		var c color.NRGBA
+		c.A = v.memory.get(address)
+		c.R = v.memory.get(address + 1)
+		c.G = v.memory.get(address + 2)
+		c.B = v.memory.get(address + 3)
+		palette[i+1] = color.RGBAModel.Convert(c).(color.RGBA)
+	}
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
+}
+
+// Sets the sprite width to NN, 0 is a width of 256. (MegaChip)
+func (v *VM) op03NN(in instruction) (err error) {
+	v.mega.spriteWidth = megaSpriteSize(in.nn)
+	v.programCounter += 2
+	return
+}
+
+// Sets the sprite height to NN, 0 is a height of 256. (MegaChip)
+func (v *VM) op04NN(in instruction) (err error) {
+	v.mega.spriteHeight = megaSpriteSize(in.nn)
+	v.programCounter += 2
+	return
+}
+
+// Sets the screen alpha to NN. (MegaChip)
+func (v *VM) op05NN(in instruction) (err error) {
+	v.graphics.alpha = in.nn
+	v.needsDraw = true
+	v.programCounter += 2
+	return
+}
+
+// Plays the digitized sound at I, looping when N is 0. (MegaChip)
+// The sound starts with a six byte header: a 16 bit sample rate, a 24 bit sample count and a reserved byte.
+func (v *VM) op060N(in instruction) (err error) {
+	address := int(v.indexRegister)
+	var s Sample
+	s.Rate = int(v.memory.get(address))<<8 | int(v.memory.get(address+1))
+	length := int(v.memory.get(address+2))<<16 | int(v.memory.get(address+3))<<8 | int(v.memory.get(address+4))
+	s.Data = make([]byte, length)
+	for i := range s.Data {
+		s.Data[i] = v.memory.get(address + 6 + i)
+	}
+
+	s.Loop = in.n == 0
+	v.mega.sample = &s
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
+}
+
+// Stops the digitized sound. (MegaChip)
+func (v *VM) op0700(in instruction) (err error) {
+	v.mega.sample = nil
+	v.programCounter += 2
+	return
+}
+
+// Sets the sprite blend mode to N: 0 normal, 1 25%, 2 50%, 3 75%, 4 additive and 5 multiply. (MegaChip)
+func (v *VM) op080N(in instruction) (err error) {
+	v.mega.blendMode = blendMode(in.n)
+	v.programCounter += 2
+	return
+}
+
+// Sets the collision color to palette index NN. (MegaChip)
+func (v *VM) op09NN(in instruction) (err error) {
+	v.mega.collisionColor = in.nn
+	v.programCounter += 2
+	return
+}
+
+// Scrolls the display up by N pixels. (MegaChip)
+func (v *VM) op00BN(in instruction) (err error) {
+	v.graphics.scrollUp(int(in.n), allPlanes)
+	v.needsDraw = true
+	v.programCounter += 2
+	return
+}
+
+// drawMegaSprite will draw a sprite made of one palette index per byte, starting at I
+// Index 0 is transparent, VF is set when a pixel drawn over has the collision color
+func (v *VM) drawMegaSprite(x, y int) {
+	width := v.graphics.Width()
+	height := v.graphics.Height()
+	address := int(v.indexRegister)
+	for yLine := 0; yLine < v.mega.spriteHeight; yLine++ {
+		py, ok := v.spriteCoordinate(y+yLine, height)
+		if !ok {
+			// Row is off screen and sprites are clipped, no more rows to draw
+			break
+		}
+
+		for xLine := 0; xLine < v.mega.spriteWidth; xLine++ {
+			index := v.memory.get(address + yLine*v.mega.spriteWidth + xLine)
+			if index == 0 {
+				// Sprite pixel is transparent, nothing to draw
+				continue
+			}
+
+			px, ok := v.spriteCoordinate(x+xLine, width)
+			if !ok {
+				// Column is off screen and sprites are clipped, no more columns to draw
+				break
+			}
+
+			i := px + (py * width)
+			if v.graphics.pixels[i] == v.mega.collisionColor {
+				// Pixel has the collision color, set collision flag
+				v.registers[0xF] = 1
+			}
+
+			v.graphics.pixels[i] = index
+			v.graphics.colors[i] = blend(v.graphics.palette[index], v.graphics.colors[i], v.mega.blendMode)
+		}
+	}
+}
+
+func megaSpriteSize(nn byte) int {
+	if size := int(nn); size > 0 {
+		return size
+	}
+
+	return 256
+}
+
+// blend will combine a sprite color with the color already on the screen
+func blend(src, dst color.RGBA, mode blendMode) color.RGBA {
+	switch mode {
+	case blend25:
+		return mix(src, dst, 1, 4)
+	case blend50:
+		return mix(src, dst, 2, 4)
+	case blend75:
+		return mix(src, dst, 3, 4)
+	case blendAdditive:
+		return color.RGBA{
+			R: addChannel(src.R, dst.R),
+			G: addChannel(src.G, dst.G),
+			B: addChannel(src.B, dst.B),
+			A: addChannel(src.A, dst.A),
+		}
+	case blendMultiply:
+		return color.RGBA{
+			R: byte(int(src.R) * int(dst.R) / 0xFF),
+			G: byte(int(src.G) * int(dst.G) / 0xFF),
+			B: byte(int(src.B) * int(dst.B) / 0xFF),
+			A: byte(int(src.A) * int(dst.A) / 0xFF),
+		}
+
+	default:
+		return src
+	}
+}
+
+// mix will return src weighted by n/d combined with dst weighted by (d-n)/d
+func mix(src, dst color.RGBA, n, d int) color.RGBA {
+	return color.RGBA{
+		R: byte((int(src.R)*n + int(dst.R)*(d-n)) / d),
+		G: byte((int(src.G)*n + int(dst.G)*(d-n)) / d),
+		B: byte((int(src.B)*n + int(dst.B)*(d-n)) / d),
+		A: byte((int(src.A)*n + int(dst.A)*(d-n)) / d),
+	}
+}
+
+func addChannel(a, b byte) byte {
+	if sum := int(a) + int(b); sum < 0xFF {
+		return byte(sum)
+	}
+
+	return 0xFF
+}
@@ -1,9 +1,42 @@
 package vm
 
-type memory [4096]byte
+const (
+	// DefaultMemorySize is the memory size of the original CHIP-8 interpreters
+	DefaultMemorySize = 4096
+	// XOChipMemorySize is the memory size of XO-CHIP
+	XOChipMemorySize = 65536
+)
 
-func (m *memory) clear() {
-	for i := 0; i < 4096; i++ {
+func newMemory(size int) memory {
+	return make(memory, size)
+}
+
+// memory is the system memory, the size must be a power of two so that addresses can wrap
+type memory []byte
+
+// get will return the byte at the provided address, addresses past the end wrap around
+func (m memory) get(address int) byte {
+	return m[address&(len(m)-1)]
+}
+
+// set will set the byte at the provided address, addresses past the end wrap around
+func (m memory) set(address int, b byte) {
+	m[address&(len(m)-1)] = b
+}
+
+// resize will return memory of the provided size which contains the current contents
+func (m memory) resize(size int) memory {
+	if len(m) == size {
+		return m
+	}
+
+	resized := newMemory(size)
+	copy(resized, m)
+	return resized
+}
+
+func (m memory) clear() {
+	for i := range m {
 		m[i] = 0
 	}
 }
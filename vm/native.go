@@ -0,0 +1,108 @@
+package vm
+
+// Native is a program which was translated ahead of time into Go
+// Instructions which have not been translated, or which have been modified since, are interpreted
+type Native interface {
+	// Block will return the translated block starting at the provided address
+	// ok is false when no block starts at the address
+	Block(address uint16) (b NativeBlock, ok bool)
+}
+
+// NativeBlock is a run of instructions which was translated into Go
+type NativeBlock struct {
+	// Run will execute the block and leave the program counter at the next instruction to execute
+	Run func(v *VM) error
+	// Size is the number of bytes the block was translated from, starting at its address
+	Size uint16
+	// Instructions is the number of instructions executed by the block
+	Instructions int
+}
+
+// SetNative will set the translated program to execute, a nil Native interprets the program
+func (v *VM) SetNative(n Native) {
+	v.native = n
+	v.modified = nil
+}
+
+// Register will return the value of register VX
+func (v *VM) Register(x byte) byte {
+	return v.registers[x&0x0F]
+}
+
+// SetRegister will set the value of register VX
+func (v *VM) SetRegister(x, value byte) {
+	v.registers[x&0x0F] = value
+}
+
+// SetIndex will set the index register
+func (v *VM) SetIndex(address uint32) {
+	v.indexRegister = address
+}
+
+// ProgramCounter will return the address of the next instruction to execute
+func (v *VM) ProgramCounter() uint16 {
+	return v.programCounter
+}
+
+// SetProgramCounter will set the address of the next instruction to execute
+func (v *VM) SetProgramCounter(address uint16) {
+	v.programCounter = address
+}
+
+// Execute will execute the provided opcode as if it was located at the program counter
+func (v *VM) Execute(o uint16) (err error) {
+	return v.executeOpcode(opcode(o))
+}
+
+// runNative will execute up to n instructions using the translated program
+func (v *VM) runNative(n int) (err error) {
+	for n > 0 && !v.exited && !v.waitForFrame {
+		b, ok := v.native.Block(v.programCounter)
+		if !ok || b.Instructions > n || v.isModified(v.programCounter, b.Size) {
+			// Block is not available or does not fit in this frame, interpret a single instruction
+			if _, err = v.Cycle(); err != nil {
+				return
+			}
+
+			n--
+			continue
+		}
+
+		if err = b.Run(v); err != nil {
+			return
+		}
+
+		n -= b.Instructions
+	}
+
+	return
+}
+
+// markModified will record that the program wrote to the provided address, translated blocks containing it are no longer used
+func (v *VM) markModified(address int) {
+	if v.native == nil {
+		return
+	}
+
+	if v.modified == nil {
+		v.modified = make([]bool, len(v.memory))
+	}
+
+	v.modified[address&(len(v.modified)-1)] = true
+}
+
+// isModified will return whether any of the size bytes starting at address have been written to
+func (v *VM) isModified(address, size uint16) bool {
+	if v.modified == nil {
+		// Nothing has been written, return
+		return false
+	}
+
+	for i := 0; i < int(size); i++ {
+		if v.modified[(int(address)+i)&(len(v.modified)-1)] {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,121 @@
+package vm
+
+import "errors"
+
+var (
+	// ErrUnknownQuirks is returned when quirks are requested for an unknown interpreter
+	ErrUnknownQuirks = errors.New("unknown interpreter, quirks not found")
+)
+
+var (
+	// VIPQuirks match the original COSMAC VIP interpreter
+	VIPQuirks = Quirks{
+		ShiftUsesVY:     true,
+		ResetVF:         true,
+		MemoryIncrement: MemoryIncrementXPlusOne,
+		DisplayWait:     true,
+	}
+
+	// CHIP48Quirks match the CHIP-48 interpreter for the HP-48
+	CHIP48Quirks = Quirks{
+		MemoryIncrement: MemoryIncrementX,
+		JumpWithVX:      true,
+	}
+
+	// SuperChipQuirks match the SUPER-CHIP 1.1 interpreter
+	SuperChipQuirks = Quirks{
+		MemoryIncrement: MemoryIncrementNone,
+		JumpWithVX:      true,
+	}
+
+	// XOChipQuirks match the XO-CHIP extension as implemented by Octo
+	XOChipQuirks = Quirks{
+		ShiftUsesVY:     true,
+		MemoryIncrement: MemoryIncrementXPlusOne,
+		WrapSprites:     true,
+		MemorySize:      XOChipMemorySize,
+	}
+
+	// ETI660Quirks match the CHIP-8 interpreter of the ETI-660, which loads programs at 0x600
+	ETI660Quirks = Quirks{
+		ShiftUsesVY:     true,
+		ResetVF:         true,
+		MemoryIncrement: MemoryIncrementXPlusOne,
+		ProgramStart:    0x600,
+	}
+
+	// MegaChipQuirks match the MegaChip 8 extension, which builds on SUPER-CHIP
+	MegaChipQuirks = Quirks{
+		MemoryIncrement: MemoryIncrementNone,
+		JumpWithVX:      true,
+		MemorySize:      MegaChipMemorySize,
+	}
+)
+
+// GetQuirks will return the quirks preset for the provided interpreter name
+// Supported names are "vip", "eti660", "chip48", "schip", "xochip" and "megachip"
+func GetQuirks(name string) (q Quirks, err error) {
+	switch name {
+	case "vip":
+		return VIPQuirks, nil
+	case "eti660":
+		return ETI660Quirks, nil
+	case "chip48":
+		return CHIP48Quirks, nil
+	case "schip":
+		return SuperChipQuirks, nil
+	case "xochip":
+		return XOChipQuirks, nil
+	case "megachip":
+		return MegaChipQuirks, nil
+
+	default:
+		err = ErrUnknownQuirks
+		return
+	}
+}
+
+// Quirks represent the behaviors which differ between CHIP-8 interpreters
+type Quirks struct {
+	// ShiftUsesVY will make 8XY6 and 8XYE shift VY and store the result in VX (COSMAC VIP)
+	// When false, VX is shifted in place and VY is ignored
+	ShiftUsesVY bool
+
+	// ResetVF will make 8XY1, 8XY2 and 8XY3 set VF to 0 (COSMAC VIP)
+	ResetVF bool
+
+	// MemoryIncrement controls how I is changed by FX55 and FX65
+	MemoryIncrement MemoryIncrement
+
+	// JumpWithVX will make BNNN behave as BXNN, jumping to XNN plus VX (CHIP-48 and SUPER-CHIP)
+	// When false, BNNN jumps to NNN plus V0 (COSMAC VIP)
+	JumpWithVX bool
+
+	// WrapSprites will draw the parts of a sprite which cross the screen edge on the opposite side
+	// When false, those parts are clipped (COSMAC VIP, SUPER-CHIP)
+	// The starting coordinate of a sprite is always wrapped
+	WrapSprites bool
+
+	// DisplayWait will halt execution after DXYN until the next frame, as the COSMAC VIP waits for the vertical blank
+	DisplayWait bool
+
+	// MemorySize is the size of the address space in bytes, it must be a power of two
+	// When zero, DefaultMemorySize is used (XO-CHIP uses XOChipMemorySize)
+	MemorySize int
+
+	// ProgramStart is the address programs are loaded to and started from
+	// When zero, 0x200 is used (ETI-660 uses 0x600)
+	ProgramStart uint16
+}
+
+// MemoryIncrement represents how I is changed after FX55 and FX65
+type MemoryIncrement uint8
+
+const (
+	// MemoryIncrementNone leaves I unmodified (SUPER-CHIP)
+	MemoryIncrementNone MemoryIncrement = iota
+	// MemoryIncrementX increments I by X (CHIP-48)
+	MemoryIncrementX
+	// MemoryIncrementXPlusOne increments I by X plus 1 (COSMAC VIP, XO-CHIP)
+	MemoryIncrementXPlusOne
+)
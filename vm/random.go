@@ -0,0 +1,32 @@
+package vm
+
+import (
+	"math/rand"
+	"time"
+)
+
+// NewRandom will return a new Random seeded with the provided seed
+// Two Randoms created with the same seed will produce the same sequence of bytes
+func NewRandom(seed int64) Random {
+	var r mathRandom
+	r.r = rand.New(rand.NewSource(seed))
+	return &r
+}
+
+// Random is a source of random bytes used by CXNN
+type Random interface {
+	Byte() byte
+}
+
+type mathRandom struct {
+	r *rand.Rand
+}
+
+// Byte will return a random byte
+func (m *mathRandom) Byte() byte {
+	return byte(m.r.Intn(256))
+}
+
+func newDefaultRandom() Random {
+	return NewRandom(time.Now().UnixNano())
+}
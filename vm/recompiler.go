@@ -0,0 +1,182 @@
+package vm
+
+const (
+	// maxBlockLength is the maximum number of instructions compiled into a single block
+	maxBlockLength = 64
+)
+
+// step executes a single compiled instruction, the operands are bound when the block is compiled
+type step func(v *VM) error
+
+// block is a basic block, a run of instructions which is always executed from the start to the end
+// Blocks end with the first instruction which may change control flow, write memory, change modes or wait
+type block struct {
+	steps []step
+}
+
+// SetRecompiler will set whether programs are executed as compiled blocks instead of being interpreted
+// Blocks are invalidated when the memory they were compiled from is written to
+func (v *VM) SetRecompiler(enabled bool) {
+	v.recompile = enabled
+	v.resetBlocks()
+}
+
+// runBlocks will execute up to n instructions using compiled blocks
+func (v *VM) runBlocks(n int) (err error) {
+	for n > 0 && !v.exited && !v.waitForFrame {
+		b := v.getBlock()
+		if b == nil {
+			// Program counter is outside of the cached address space, interpret a single instruction
+			if _, err = v.Cycle(); err != nil {
+				return
+			}
+
+			n--
+			continue
+		}
+
+		steps := b.steps
+		if len(steps) > n {
+			// Block is longer than the remaining instructions, execute part of it
+			steps = steps[:n]
+		}
+
+		for _, s := range steps {
+			if err = s(v); err != nil {
+				return
+			}
+		}
+
+		n -= len(steps)
+	}
+
+	return
+}
+
+// getBlock will return the block starting at the program counter, compiling it if needed
+func (v *VM) getBlock() (b *block) {
+	pc := int(v.programCounter)
+	if pc >= len(v.decoded) {
+		return
+	}
+
+	if len(v.blocks) != len(v.decoded) {
+		// Block cache does not match the memory size, allocate
+		v.blocks = make([]*block, len(v.decoded))
+		v.compiled = make([]bool, len(v.decoded))
+	}
+
+	if b = v.blocks[pc]; b != nil {
+		return
+	}
+
+	b = v.compileBlock(pc)
+	v.blocks[pc] = b
+	return
+}
+
+// compileBlock will compile the block starting at the provided address
+func (v *VM) compileBlock(start int) (b *block) {
+	b = &block{}
+	for address := start; address+1 < len(v.decoded) && len(b.steps) < maxBlockLength; address += 2 {
+		o := opcode(v.memory[address])<<8 | opcode(v.memory[address+1])
+		// Mark both bytes so that writing to them invalidates the block
+		v.compiled[address] = true
+		v.compiled[address+1] = true
+
+		in := *v.decode(o)
+		b.steps = append(b.steps, compileStep(in))
+		if !isStraight(o) {
+			// Instruction may leave the block, end it
+			break
+		}
+	}
+
+	return
+}
+
+// invalidateBlocks will remove all compiled blocks when the provided address was compiled into one
+func (v *VM) invalidateBlocks(address int) {
+	if address < len(v.compiled) && v.compiled[address] {
+		v.resetBlocks()
+	}
+}
+
+// resetBlocks will remove all compiled blocks
+func (v *VM) resetBlocks() {
+	for i := range v.blocks {
+		v.blocks[i] = nil
+		v.compiled[i] = false
+	}
+}
+
+// compileStep will return a step which executes the provided instruction
+// The most common instructions are executed directly, the rest call their handler with the bound instruction
+func compileStep(in instruction) step {
+	x, y := in.x, in.y
+	switch in.opcode & 0xF000 {
+	case 0x6000:
+		nn := in.nn
+		return func(v *VM) error {
+			v.registers[x] = nn
+			v.programCounter += 2
+			return nil
+		}
+
+	case 0x7000:
+		nn := in.nn
+		return func(v *VM) error {
+			v.registers[x] += nn
+			v.programCounter += 2
+			return nil
+		}
+
+	case 0xA000:
+		nnn := uint32(in.nnn)
+		return func(v *VM) error {
+			v.indexRegister = nnn
+			v.programCounter += 2
+			return nil
+		}
+	}
+
+	if in.opcode&0xF00F == 0x8000 {
+		return func(v *VM) error {
+			v.registers[x] = v.registers[y]
+			v.programCounter += 2
+			return nil
+		}
+	}
+
+	h := in.handler
+	return func(v *VM) error {
+		return h(v, in)
+	}
+}
+
+// isStraight will return whether an opcode always continues with the next instruction without writing memory or changing modes
+func isStraight(o opcode) bool {
+	switch o & 0xF000 {
+	case 0x6000, 0x7000, 0xA000, 0xC000:
+		return true
+
+	case 0x8000:
+		switch o & 0x000F {
+		case 0x0000, 0x0001, 0x0002, 0x0003, 0x0004, 0x0005, 0x0006, 0x0007, 0x000E:
+			return true
+		}
+
+	case 0xF000:
+		if o == 0xF000 || o == 0xF002 {
+			// Long index load reads the next word, F002 is left to the interpreter with the rest of the audio opcodes
+			return false
+		}
+
+		switch o & 0x00FF {
+		case 0x0001, 0x0007, 0x0015, 0x0018, 0x001E, 0x0029, 0x0030, 0x003A, 0x0065, 0x0075, 0x0085:
+			return true
+		}
+	}
+
+	return false
+}
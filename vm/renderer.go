@@ -1,7 +1,15 @@
 package vm
 
 // Renderer will render Graphics output
+// Graphics of MegaChip programs are true color, renderers should then draw the colors and palette
+// they carry (see Graphics.IsTrueColor) instead of mapping pixel values to their own colors
 type Renderer interface {
 	Draw(Graphics) error
 	GetKeypad() Keypad
 }
+
+// Updater is implemented by Renderers which need to run every frame, such as to poll a window for input
+// Draw is only called on the frames where the Graphics have changed, Update is called on every frame
+type Updater interface {
+	Update() error
+}
@@ -3,8 +3,8 @@ package vm
 import (
 	"context"
 	"errors"
-	"fmt"
 	"io/ioutil"
+	"math"
 	"time"
 )
 
@@ -14,59 +14,197 @@ var (
 )
 
 const (
-	errInvalidOpcodeFmt = "invalid opcode, %s is not supported"
+	// DefaultStackDepth is the stack depth used when one has not been set
+	DefaultStackDepth = 16
+	// VIPStackDepth is the stack depth of the original COSMAC VIP interpreter
+	VIPStackDepth = 12
 )
 
 const (
-	cyclesPerSecond  = 60
-	durationPerCycle = time.Second / cyclesPerSecond
+	// defaultPitch is the XO-CHIP pitch which plays the audio pattern buffer at 4000Hz
+	defaultPitch = 64
 )
 
 const (
-	errOpcodeNotImplementedFmt = "opcode %s not implemented"
+	// FramesPerSecond is the rate the timers are decremented and the display is refreshed at
+	FramesPerSecond = 60
+	// DefaultInstructionsPerFrame is the number of instructions executed per frame when one has not been set (600Hz)
+	DefaultInstructionsPerFrame = 10
+
+	durationPerFrame = time.Second / FramesPerSecond
 )
 
 // VM emulates a chip8 instance
 // The system's memory map
 // 0x000-0x1FF - Chip 8 interpreter (contains font set in emu)
 // 0x050-0x0A0 - Used for the built in 4x5 pixel font set (0-F)
-// 0x200-0xFFF - Program ROM and work RAM
+// 0x0A0-0x140 - Used for the built in 8x10 pixel font set (0-F)
+// 0x200-0xFFF - Program ROM and work RAM (0x200-0xFFFF for XO-CHIP)
+// Programs start at the ProgramStart quirk instead of 0x200 when it is set (0x600 for ETI-660)
 type VM struct {
 	memory    memory
 	registers [16]byte
 	stack     [16]uint16
 
 	programCounter uint16
-	indexRegister  uint16
+	indexRegister  uint32
 	stackPointer   uint16
+	stackDepth     uint16
 	currentOpcode  opcode
 
+	// Decoded instructions cached by address
+	decoded []*instruction
+
+	// Compiled blocks by start address and the addresses compiled into them, used when recompile is set
+	blocks    []*block
+	compiled  []bool
+	recompile bool
+
+	// Program translated ahead of time and the addresses written to since it was loaded
+	native   Native
+	modified []bool
+
+	// Number of instructions executed per 60Hz frame
+	instructionsPerFrame int
+
 	graphics Graphics
 	keypad   Keypad
 
 	// Flags
 	needsDraw bool
 
+	// Key wait state for FX0A
+	waitKeyPressed bool
+	waitKey        byte
+
+	// Set by DXYN when the DisplayWait quirk is enabled, no instructions are executed until the next frame
+	waitForFrame bool
+	// Set by 00FD, the program has exited
+	exited bool
+	// Set by Load when the program targets the two-page 64x64 CHIP-8 HIRES interpreter
+	twoPage bool
+
+	// SUPER-CHIP RPL user flags
+	rplFlags [16]byte
+
+	// XO-CHIP bit planes selected for drawing
+	planes byte
+	// XO-CHIP audio pattern buffer and pitch
+	audioPattern [16]byte
+	pitch        byte
+
+	// MegaChip state
+	mega megaChip
+
 	// Timers
 	delayTimer byte
 	soundTimer byte
 
 	// Renderer
 	r Renderer
+	// Sink for the buzzer, nothing is played when nil
+	audio Audio
+
+	// Random source for CXNN
+	random Random
+
+	// Interpreter quirks
+	quirks Quirks
 }
 
-// Initialize will initialize the VM
+// Initialize will initialize the VM, the program copied to memory by Load is kept
 func (v *VM) Initialize(r Renderer) {
-	// Clear memory and counters
-	v.programCounter = 0x200
+	// Clear counters, registers, the stack and timers
+	v.programCounter = v.getProgramStart()
 	v.indexRegister = 0
 	v.currentOpcode = 0
+	v.registers = [16]byte{}
+	v.stack = [16]uint16{}
+	v.stackPointer = 0
+	v.delayTimer = 0
+	v.soundTimer = 0
+
+	// Clear the keypad, any key or display wait and the SUPER-CHIP RPL flags
+	v.keypad = Keypad{}
+	v.waitKeyPressed = false
+	v.waitKey = 0
+	v.waitForFrame = false
+	v.rplFlags = [16]byte{}
 
 	// Set renderer
 	v.r = r
 
+	// Start in low resolution mode with the first plane selected
+	v.resetGraphics()
+	v.planes = 1
+	v.audioPattern = [16]byte{}
+	v.pitch = defaultPitch
+	v.mega = megaChip{}
+	v.exited = false
+	// Draw the blank screen on the first frame
+	v.needsDraw = true
+
+	// Allocate memory for the current quirks
+	v.allocateMemory()
+
 	// Copy fontset bytes to memory starting at 0x50
-	copy(v.memory[0x50:], fontset[:])
+	copy(v.memory[fontsetOffset:], fontset[:])
+	// Copy big fontset bytes to memory directly after the fontset
+	copy(v.memory[bigFontsetOffset:], bigFontset[:])
+}
+
+// SetStackDepth will set the maximum number of nested subroutine calls
+// Use VIPStackDepth to match the original COSMAC VIP, or DefaultStackDepth for later interpreters
+func (v *VM) SetStackDepth(depth int) (err error) {
+	if depth < 1 || depth > len(v.stack) {
+		return ErrInvalidStackDepth
+	}
+
+	v.stackDepth = uint16(depth)
+	return
+}
+
+// SetInstructionsPerFrame will set the number of instructions executed during each 60Hz frame
+// The resulting CPU speed in Hz is the instructions per frame multiplied by FramesPerSecond
+func (v *VM) SetInstructionsPerFrame(n int) (err error) {
+	if n < 1 {
+		return ErrInvalidInstructionsPerFrame
+	}
+
+	v.instructionsPerFrame = n
+	return
+}
+
+// SetClockSpeed will set the number of instructions executed per second, rounded to whole instructions per frame
+func (v *VM) SetClockSpeed(hz int) (err error) {
+	return v.SetInstructionsPerFrame((hz + FramesPerSecond/2) / FramesPerSecond)
+}
+
+// SetQuirks will set the interpreter quirks used when executing opcodes
+// Memory is resized to the MemorySize of the quirks, existing contents are kept
+func (v *VM) SetQuirks(q Quirks) (err error) {
+	if q.MemorySize != 0 && (q.MemorySize < DefaultMemorySize || q.MemorySize&(q.MemorySize-1) != 0) {
+		return ErrInvalidMemorySize
+	}
+
+	if int(q.ProgramStart) >= DefaultMemorySize && int(q.ProgramStart) >= q.MemorySize {
+		return ErrInvalidProgramStart
+	}
+
+	v.quirks = q
+	v.allocateMemory()
+	return
+}
+
+// SetRandom will set the random source used by CXNN
+// Set a Random created with NewRandom and a fixed seed to make runs reproducible
+func (v *VM) SetRandom(r Random) {
+	v.random = r
+}
+
+// SetAudio will set the sink the buzzer is played to, the VM is silent when it is nil
+func (v *VM) SetAudio(a Audio) {
+	v.audio = a
 }
 
 // Load will load a game into the Virtual Machine
@@ -77,26 +215,125 @@ func (v *VM) Load(filename string) (err error) {
 		return
 	}
 
-	// Copy program bytes to memory starting at 0x200
-	copy(v.memory[0x200:], bs)
+	// Allocate memory for the current quirks
+	v.allocateMemory()
+
+	start := int(v.getProgramStart())
+	if len(bs) > len(v.memory)-start {
+		// Program does not fit in memory, return
+		return ErrProgramTooLarge
+	}
+
+	// Copy program bytes to memory starting at the program start (0x200 by default)
+	copy(v.memory[start:], bs)
+
+	// Programs for the two-page CHIP-8 HIRES interpreter start by jumping past its 0x1260 prologue
+	v.twoPage = start == 0x200 && len(bs) >= 2 && bs[0] == 0x12 && bs[1] == 0x60
+	if v.twoPage {
+		// Jump straight to the program, as the prologue patches the original interpreter
+		v.memory[start+1] = 0xC0
+	}
+
+	// Program has been replaced, nothing has been written to it yet
+	v.modified = nil
+
+	v.resetGraphics()
 	return
 }
 
-// Cycle will emulate a chip8 cycle
+// Graphics will return the current display
+func (v *VM) Graphics() Graphics {
+	return v.graphics
+}
+
+// Screenshot will write the current display to a PNG file
+// It must not be called while Run is executing on another goroutine, renderers receive the Graphics to capture instead
+func (v *VM) Screenshot(filename string, opts ImageOptions) (err error) {
+	return v.graphics.SavePNG(filename, opts)
+}
+
+// AudioPattern will return the XO-CHIP audio pattern buffer, 128 one bit samples played while the sound timer is active
+func (v *VM) AudioPattern() (pattern [16]byte) {
+	return v.audioPattern
+}
+
+// Buzzing will return whether the buzzer is sounding, which is while the sound timer is non-zero
+func (v *VM) Buzzing() bool {
+	return v.soundTimer > 0
+}
+
+// PlaybackRate will return the rate in Hz the XO-CHIP audio pattern buffer is played at, as set by FX3A
+func (v *VM) PlaybackRate() float64 {
+	return 4000 * math.Pow(2, (float64(v.pitch)-64)/48)
+}
+
+// Cycle will emulate a single chip8 instruction
+// Timers are not updated, they are decremented once per Frame
 func (v *VM) Cycle() (needsDraw bool, err error) {
-	// Fetch Opcode
-	var o opcode
-	if o, err = v.fetchOpcode(); err != nil {
+	if v.exited || v.waitForFrame {
+		// Program has exited or is waiting for the next frame, nothing to do
 		return
 	}
 
-	// Execute Opcode
-	if err = v.executeOpcode(o); err != nil {
+	// Fetch decoded instruction
+	in := v.fetch()
+
+	// Execute instruction
+	if err = in.handler(v, *in); err != nil {
+		return
+	}
+
+	needsDraw = v.needsDraw
+	return
+}
+
+// Frame will emulate a single 60Hz frame
+// Instructions are executed until the instructions per frame are reached, the program waits for the next frame or exits
+// The frame is then played to the Audio and timers are decremented once, needsDraw reports whether the display changed during the frame
+func (v *VM) Frame() (needsDraw bool, err error) {
+	// A new frame has started, release any display wait
+	v.waitForFrame = false
+
+	if err = v.execute(v.getInstructionsPerFrame()); err != nil {
 		return
 	}
 
+	if v.audio != nil {
+		// Play the buzzer while the sound timer is non-zero
+		if err = v.audio.Play(v.soundTimer > 0); err != nil {
+			return
+		}
+	}
+
 	// Update timers
 	v.updateTimers()
+
+	needsDraw = v.needsDraw
+	v.needsDraw = false
+	return
+}
+
+// execute will execute up to n instructions, stopping early when the program waits for the next frame or exits
+func (v *VM) execute(n int) (err error) {
+	if v.native != nil {
+		return v.runNative(n)
+	}
+
+	if v.recompile {
+		return v.runBlocks(n)
+	}
+
+	for i := 0; i < n; i++ {
+		if v.exited || v.waitForFrame {
+			// Nothing else will be executed during this frame, break
+			break
+		}
+
+		if _, err = v.Cycle(); err != nil {
+			return
+		}
+	}
+
 	return
 }
 
@@ -112,246 +349,199 @@ func (v *VM) Run(ctx context.Context) (err error) {
 		return
 	}
 
-	var needsDraw bool
-	tkr := time.NewTicker(durationPerCycle)
+	tkr := time.NewTicker(durationPerFrame)
+	defer tkr.Stop()
 	for range tkr.C {
 		if isDone(ctx) {
 			// Context is finished, return
 			return
 		}
 
-		if needsDraw, err = v.Cycle(); err != nil {
+		if err = v.RunFrame(); err != nil {
 			return
-		} else if needsDraw {
-
 		}
 
-		if err = v.r.Draw(v.graphics); err != nil {
+		if v.exited {
+			// Program has exited, return
 			return
 		}
-
-		v.SetKeys()
 	}
 
 	return
 }
 
-func (v *VM) fetchOpcode() (o opcode, err error) {
-	// Get first byte from program counter
-	firstByte := v.memory[v.programCounter]
-	// Get second byte from program counter
-	secondByte := v.memory[v.programCounter+1]
-	// Combine bytes to become an opcode
-	o = opcode(firstByte)<<8 | opcode(secondByte)
-	return
-}
-
-func (v *VM) execute0x0000(o opcode) (err error) {
-	switch o & 0x000F {
-	case 0x0000:
-		return v.op00E0(o)
-	case 0x000E:
-		return v.op00EE(o)
-
-	default:
-		return v.op0NNN(o)
+// RunFrame will run a single frame, draw the Graphics when they have changed and read the keypad from the renderer
+// Run calls it at 60Hz, it can be called directly to run faster than real time, such as when exporting video
+func (v *VM) RunFrame() (err error) {
+	if v.r == nil {
+		err = ErrRendererNotSet
+		return
 	}
-}
-
-func (v *VM) execute0x1000(o opcode) (err error) {
-	return v.op1NNN(o)
-}
 
-func (v *VM) execute0x2000(o opcode) (err error) {
-	return v.op2NNN(o)
-}
+	var needsDraw bool
+	if needsDraw, err = v.Frame(); err != nil {
+		return
+	} else if needsDraw {
+		// Graphics have changed, draw them
+		if err = v.r.Draw(v.graphics); err != nil {
+			return
+		}
+	}
 
-func (v *VM) execute0x3000(o opcode) (err error) {
-	return v.op3XNN(o)
-}
+	if updater, ok := v.r.(Updater); ok {
+		// Renderer needs to run every frame
+		if err = updater.Update(); err != nil {
+			return
+		}
+	}
 
-func (v *VM) execute0x4000(o opcode) (err error) {
-	return v.op4XNN(o)
-}
+	if v.exited {
+		// Program has exited, the keypad is no longer needed
+		return
+	}
 
-func (v *VM) execute0x5000(o opcode) (err error) {
-	return v.op5XY0(o)
+	v.SetKeys()
+	return
 }
 
-func (v *VM) execute0x6000(o opcode) (err error) {
-	return v.op6XNN(o)
+// Exited will return whether the program has exited (SUPER-CHIP 00FD)
+func (v *VM) Exited() bool {
+	return v.exited
 }
 
-func (v *VM) execute0x7000(o opcode) (err error) {
-	return v.op7XNN(o)
+func (v *VM) fetchOpcode() (o opcode) {
+	// Get first byte from program counter
+	firstByte := v.memory.get(int(v.programCounter))
+	// Get second byte from program counter
+	secondByte := v.memory.get(int(v.programCounter) + 1)
+	// Combine bytes to become an opcode
+	o = opcode(firstByte)<<8 | opcode(secondByte)
+	return
 }
 
-func (v *VM) execute0x8000(o opcode) (err error) {
-	switch o & 0x000F {
-	case 0x0000:
-		return v.op8XY0(o)
-	case 0x0001:
-		return v.op8XY0(o)
-	case 0x0002:
-		return v.op8XY0(o)
-	case 0x0003:
-		return v.op8XY0(o)
-	case 0x0004:
-		return v.op8XY0(o)
-	case 0x0005:
-		return v.op8XY0(o)
-	case 0x0006:
-		return v.op8XY0(o)
-	case 0x0007:
-		return v.op8XY0(o)
-	case 0x000E:
-		return v.op8XY0(o)
-
-	default:
-		return fmt.Errorf(errInvalidOpcodeFmt, o.toHex())
-	}
+// executeOpcode will decode and execute the provided opcode
+func (v *VM) executeOpcode(o opcode) (err error) {
+	in := v.decode(o)
+	return in.handler(v, *in)
 }
 
-func (v *VM) execute0x9000(o opcode) (err error) {
-	return v.op9XY0(o)
+// Calls machine code routine (RCA 1802 for COSMAC VIP) at address NNN. Not necessary for most ROMs.
+// Programs which need this can be run on the full COSMAC VIP emulation in the cosmac package.
+func (v *VM) op0NNN(in instruction) (err error) {
+	return v.newOpcodeError(ErrOpcodeNotImplemented, in.opcode)
 }
 
-func (v *VM) execute0xA000(o opcode) (err error) {
-	return v.opANNN(o)
+// Clears the screen.
+func (v *VM) op00E0(in instruction) (err error) {
+	// Only the selected planes are cleared (XO-CHIP)
+	v.graphics.clearPlanes(v.planes)
+	v.needsDraw = true
+	v.programCounter += 2
+	return
 }
 
-func (v *VM) execute0xB000(o opcode) (err error) {
-	return v.opBNNN(o)
+// Clears the screen. (CHIP-8 HIRES)
+func (v *VM) op0230(in instruction) (err error) {
+	v.graphics.clear()
+	v.needsDraw = true
+	v.programCounter += 2
+	return
 }
 
-func (v *VM) execute0xC000(o opcode) (err error) {
-	return v.opCXNN(o)
-}
+// Returns from a subroutine.
+func (v *VM) op00EE(in instruction) (err error) {
+	if v.stackPointer == 0 {
+		// Stack is empty, return
+		return v.newStackError(ErrStackUnderflow, in.opcode)
+	}
 
-func (v *VM) execute0xD000(o opcode) (err error) {
-	return v.opDXYN(o)
+	// Decrement stack pointer
+	v.stackPointer--
+	// Point program counter to the instruction after the original call
+	v.programCounter = v.stack[v.stackPointer] + 2
+	return
 }
 
-func (v *VM) execute0xE000(o opcode) (err error) {
-	switch o & 0x000F {
-	case 0x000E:
-		return v.opEX9E(o)
-	case 0x0001:
-		return v.opEXA1(o)
-
-	default:
-		return fmt.Errorf(errInvalidOpcodeFmt, o.toHex())
-	}
+// Scrolls the display down by N pixels. (SUPER-CHIP)
+func (v *VM) op00CN(in instruction) (err error) {
+	v.graphics.scrollDown(int(in.n), v.planes)
+	v.needsDraw = true
+	v.programCounter += 2
+	return
 }
 
-func (v *VM) execute0xF000(o opcode) (err error) {
-	switch o & 0x00FF {
-	case 0x0007:
-		return v.opFX07(o)
-	case 0x000A:
-		return v.opFX0A(o)
-	case 0x0015:
-		return v.opFX15(o)
-	case 0x0018:
-		return v.opFX18(o)
-	case 0x001E:
-		return v.opFX1E(o)
-	case 0x0029:
-		return v.opFX29(o)
-	case 0x0033:
-		return v.opFX33(o)
-	case 0x0055:
-		return v.opFX55(o)
-	case 0x0065:
-		return v.opFX65(o)
-
-	default:
-		return fmt.Errorf(errInvalidOpcodeFmt, o.toHex())
-	}
+// Scrolls the display right by 4 pixels. (SUPER-CHIP)
+func (v *VM) op00FB(in instruction) (err error) {
+	v.graphics.scrollRight(4, v.planes)
+	v.needsDraw = true
+	v.programCounter += 2
+	return
 }
 
-func (v *VM) executeOpcode(o opcode) (err error) {
-	switch o & 0xF000 {
-	case 0x0000:
-		return v.execute0x0000(o)
-	case 0x1000:
-		return v.execute0x1000(o)
-	case 0x2000:
-		return v.execute0x2000(o)
-	case 0x3000:
-		return v.execute0x3000(o)
-	case 0x4000:
-		return v.execute0x4000(o)
-	case 0x5000:
-		return v.execute0x5000(o)
-	case 0x6000:
-		return v.execute0x6000(o)
-	case 0x7000:
-		return v.execute0x7000(o)
-	case 0x8000:
-		return v.execute0x8000(o)
-	case 0x9000:
-		return v.execute0x9000(o)
-	case 0xA000:
-		return v.execute0xA000(o)
-	case 0xB000:
-		return v.execute0xB000(o)
-	case 0xC000:
-		return v.execute0xC000(o)
-	case 0xD000:
-		return v.execute0xD000(o)
-	case 0xE000:
-		return v.execute0xE000(o)
-	case 0xF000:
-		return v.execute0xF000(o)
-
-	default:
-		return fmt.Errorf(errInvalidOpcodeFmt, o.toHex())
-	}
+// Scrolls the display left by 4 pixels. (SUPER-CHIP)
+func (v *VM) op00FC(in instruction) (err error) {
+	v.graphics.scrollLeft(4, v.planes)
+	v.needsDraw = true
+	v.programCounter += 2
+	return
 }
 
-// Calls machine code routine (RCA 1802 for COSMAC VIP) at address NNN. Not necessary for most ROMs.
-func (v *VM) op0NNN(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "0NNN")
+// Exits the interpreter. (SUPER-CHIP)
+func (v *VM) op00FD(in instruction) (err error) {
+	v.exited = true
+	return
 }
 
-// Clears the screen.
-func (v *VM) op00E0(o opcode) (err error) {
-	v.graphics.clear()
+// Disables high resolution mode, the screen becomes 64x32 and is cleared. (SUPER-CHIP)
+func (v *VM) op00FE(in instruction) (err error) {
+	// The screen has no colors, MegaChip mode ends
+	v.leaveMegaChip()
+	v.graphics = newGraphics(graphicsWidth, graphicsHeight)
+	v.needsDraw = true
 	v.programCounter += 2
 	return
 }
 
-// Returns from a subroutine.
-func (v *VM) op00EE(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "00EE")
+// Enables high resolution mode, the screen becomes 128x64 and is cleared. (SUPER-CHIP)
+func (v *VM) op00FF(in instruction) (err error) {
+	// The screen has no colors, MegaChip mode ends
+	v.leaveMegaChip()
+	v.graphics = newGraphics(hiresGraphicsWidth, hiresGraphicsHeight)
+	v.needsDraw = true
+	v.programCounter += 2
+	return
 }
 
 // Jumps to address NNN.
-func (v *VM) op1NNN(o opcode) (err error) {
-	v.programCounter = uint16(o) & 0x0FFF
+func (v *VM) op1NNN(in instruction) (err error) {
+	v.programCounter = in.nnn
 	return
 }
 
 // Calls subroutine at NNN.
-func (v *VM) op2NNN(o opcode) (err error) {
+func (v *VM) op2NNN(in instruction) (err error) {
+	if v.stackPointer >= v.getStackDepth() {
+		// Stack is full, return
+		return v.newStackError(ErrStackOverflow, in.opcode)
+	}
+
 	// Set current program counter to the stack
 	v.stack[v.stackPointer] = v.programCounter
 	// Increment stack pointer
 	v.stackPointer++
 	// Point program counter to NNN
-	v.programCounter = uint16(o) & 0x0FFF
+	v.programCounter = in.nnn
 	return
 }
 
 // Skips the next instruction if VX equals NN. (Usually the next instruction is a jump to skip a code block)
-func (v *VM) op3XNN(o opcode) (err error) {
-	vx := v.registers[(o&0x0F00)>>8]
-	nn := byte(o & 0x00FF)
+func (v *VM) op3XNN(in instruction) (err error) {
+	vx := v.registers[in.x]
+	nn := in.nn
 
 	if vx == nn {
-		// vx equals nn, skip next instruction by incrementing program counter by two
-		v.programCounter += 2
+		// vx equals nn, skip next instruction
+		v.skipNextInstruction()
 	}
 
 	// Increment program counter by 2
@@ -360,19 +550,61 @@ func (v *VM) op3XNN(o opcode) (err error) {
 }
 
 // Skips the next instruction if VX doesn't equal NN. (Usually the next instruction is a jump to skip a code block)
-func (v *VM) op4XNN(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "4XNN")
+func (v *VM) op4XNN(in instruction) (err error) {
+	vx := v.registers[in.x]
+	nn := in.nn
+
+	if vx != nn {
+		// vx does not equal nn, skip next instruction
+		v.skipNextInstruction()
+	}
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Skips the next instruction if VX equals VY. (Usually the next instruction is a jump to skip a code block)
-func (v *VM) op5XY0(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "5XY0")
+func (v *VM) op5XY0(in instruction) (err error) {
+	vx := v.registers[in.x]
+	vy := v.registers[in.y]
+
+	if vx == vy {
+		// vx equals vy, skip next instruction
+		v.skipNextInstruction()
+	}
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
+}
+
+// Saves VX to VY (including VY) in memory starting at address I, in reverse order when X is greater than Y. I is not modified. (XO-CHIP)
+func (v *VM) op5XY2(in instruction) (err error) {
+	v.forEachRegisterInRange(in, func(register, offset int) {
+		v.store(int(v.indexRegister)+offset, v.registers[register])
+	})
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
+}
+
+// Loads VX to VY (including VY) from memory starting at address I, in reverse order when X is greater than Y. I is not modified. (XO-CHIP)
+func (v *VM) op5XY3(in instruction) (err error) {
+	v.forEachRegisterInRange(in, func(register, offset int) {
+		v.registers[register] = v.memory.get(int(v.indexRegister) + offset)
+	})
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Sets VX to NN.
-func (v *VM) op6XNN(o opcode) (err error) {
+func (v *VM) op6XNN(in instruction) (err error) {
 	// Set VX to NN
-	v.registers[(o&0x0F00)>>8] = byte(o & 0x00FF)
+	v.registers[in.x] = in.nn
 
 	// Increment program counter by 2
 	v.programCounter += 2
@@ -380,9 +612,9 @@ func (v *VM) op6XNN(o opcode) (err error) {
 }
 
 // Adds NN to VX. (Carry flag is not changed)
-func (v *VM) op7XNN(o opcode) (err error) {
+func (v *VM) op7XNN(in instruction) (err error) {
 	// Add NN to VX
-	v.registers[(o&0x0F00)>>8] += byte(o & 0x00FF)
+	v.registers[in.x] += in.nn
 
 	// Increment program counter by 2
 	v.programCounter += 2
@@ -390,38 +622,61 @@ func (v *VM) op7XNN(o opcode) (err error) {
 }
 
 // Sets VX to the value of VY.
-func (v *VM) op8XY0(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "8XY0")
+func (v *VM) op8XY0(in instruction) (err error) {
+	// Set VX to VY
+	v.registers[in.x] = v.registers[in.y]
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Sets VX to VX or VY. (Bitwise OR operation)
-func (v *VM) op8XY1(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "8XY1")
+// VF is set to 0 when the ResetVF quirk is set.
+func (v *VM) op8XY1(in instruction) (err error) {
+	// Set VX to VX | VY
+	v.registers[in.x] |= v.registers[in.y]
+	v.resetFlag()
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Sets VX to VX and VY. (Bitwise AND operation)
-func (v *VM) op8XY2(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "8XY2")
+// VF is set to 0 when the ResetVF quirk is set.
+func (v *VM) op8XY2(in instruction) (err error) {
+	// Set VX to VX & VY
+	v.registers[in.x] &= v.registers[in.y]
+	v.resetFlag()
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Sets VX to VX xor VY.
-func (v *VM) op8XY3(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "8xy3")
+// VF is set to 0 when the ResetVF quirk is set.
+func (v *VM) op8XY3(in instruction) (err error) {
+	// Set VX to VX ^ VY
+	v.registers[in.x] ^= v.registers[in.y]
+	v.resetFlag()
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Adds VY to VX. VF is set to 1 when there's a carry, and to 0 when there isn't.
-func (v *VM) op8XY4(o opcode) (err error) {
-	// Get the carry state from the register
-	if v.registers[(o&0x00F0)>>4] > (0xFF - v.registers[(o&0x0F00)>>8]) {
-		// Carry, set VF to 1
-		v.registers[0xF] = 1
-	} else {
-		// No carry, set VF to 0
-		v.registers[0xF] = 0
-	}
+func (v *VM) op8XY4(in instruction) (err error) {
+	vx := v.registers[in.x]
+	vy := v.registers[in.y]
 
 	// Add VY to VX
-	v.registers[(o&0x0F00)>>8] += v.registers[(o&0x00F0)>>4]
+	v.registers[in.x] = vx + vy
+
+	// Set VF after the result so that the flag wins when X is F
+	v.registers[0xF] = boolToByte(vy > 0xFF-vx)
 
 	// Increment program counter by 2
 	v.programCounter += 2
@@ -429,128 +684,334 @@ func (v *VM) op8XY4(o opcode) (err error) {
 }
 
 // VY is subtracted from VX. VF is set to 0 when there's a borrow, and 1 when there isn't.
-func (v *VM) op8XY5(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "8XY5")
+func (v *VM) op8XY5(in instruction) (err error) {
+	vx := v.registers[in.x]
+	vy := v.registers[in.y]
+
+	// Subtract VY from VX
+	v.registers[in.x] = vx - vy
+
+	// Set VF after the result so that the flag wins when X is F
+	v.registers[0xF] = boolToByte(vx >= vy)
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Stores the least significant bit of VX in VF and then shifts VX to the right by 1.[b]
-func (v *VM) op8XY6(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "8XY6")
+// When the ShiftUsesVY quirk is set, VY is shifted and the result is stored in VX.
+func (v *VM) op8XY6(in instruction) (err error) {
+	vx := v.getShiftSource(in)
+
+	// Shift VX to the right by 1
+	v.registers[in.x] = vx >> 1
+
+	// Set VF to the bit which was shifted out
+	v.registers[0xF] = vx & 0x01
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Sets VX to VY minus VX. VF is set to 0 when there's a borrow, and 1 when there isn't.
-func (v *VM) op8XY7(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "8XY7")
+func (v *VM) op8XY7(in instruction) (err error) {
+	vx := v.registers[in.x]
+	vy := v.registers[in.y]
+
+	// Set VX to VY minus VX
+	v.registers[in.x] = vy - vx
+
+	// Set VF after the result so that the flag wins when X is F
+	v.registers[0xF] = boolToByte(vy >= vx)
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Stores the most significant bit of VX in VF and then shifts VX to the left by 1.[b]
-func (v *VM) op8XYE(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "8XYE")
+// When the ShiftUsesVY quirk is set, VY is shifted and the result is stored in VX.
+func (v *VM) op8XYE(in instruction) (err error) {
+	vx := v.getShiftSource(in)
+
+	// Shift VX to the left by 1
+	v.registers[in.x] = vx << 1
+
+	// Set VF to the bit which was shifted out
+	v.registers[0xF] = vx >> 7
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Skips the next instruction if VX doesn't equal VY. (Usually the next instruction is a jump to skip a code block)
-func (v *VM) op9XY0(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "9XY0")
+func (v *VM) op9XY0(in instruction) (err error) {
+	vx := v.registers[in.x]
+	vy := v.registers[in.y]
+
+	if vx != vy {
+		// vx does not equal vy, skip next instruction
+		v.skipNextInstruction()
+	}
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Sets I to the address NNN.
-func (v *VM) opANNN(o opcode) (err error) {
-	v.indexRegister = uint16(o & 0x0FFF)
+func (v *VM) opANNN(in instruction) (err error) {
+	v.indexRegister = uint32(in.nnn)
 	v.programCounter += 2
 	return
 }
 
 // Jumps to the address NNN plus V0.
-func (v *VM) opBNNN(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "BNNN")
+// When the JumpWithVX quirk is set, this behaves as BXNN and jumps to the address XNN plus VX.
+func (v *VM) opBNNN(in instruction) (err error) {
+	offset := v.registers[0]
+	if v.quirks.JumpWithVX {
+		// Quirk is set, use VX as the offset
+		offset = v.registers[in.x]
+	}
+
+	v.programCounter = in.nnn + uint16(offset)
+	return
 }
 
 // Sets VX to the result of a bitwise and operation on a random number (Typically: 0 to 255) and NN.
-func (v *VM) opCXNN(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "CXNN")
+func (v *VM) opCXNN(in instruction) (err error) {
+	if v.random == nil {
+		// Random source has not been set, use the default
+		v.random = newDefaultRandom()
+	}
+
+	// Set VX to a random byte masked by NN
+	v.registers[in.x] = v.random.Byte() & in.nn
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Draws a sprite at coordinate (VX, VY) that has a width of 8 pixels and a height of N pixels.
 // Each row of 8 pixels is read as bit-coded starting from memory location I; I value doesn’t change after the execution of this instruction.
 // As described above, VF is set to 1 if any screen pixels are flipped from set to unset when the sprite is drawn, and to 0 if that doesn’t happen
-func (v *VM) opDXYN(o opcode) (err error) {
-	var pixel byte
-	x := uint16(v.registers[(o&0x0F00)>>8])
-	y := uint16(v.registers[(o&0x00F0)>>4])
-	height := uint16(o) & 0x000F
+// When N is 0, a 16x16 sprite is drawn, with each row read as two bytes. (SUPER-CHIP)
+func (v *VM) opDXYN(in instruction) (err error) {
+	width := v.graphics.Width()
+	height := v.graphics.Height()
+	// Starting coordinates always wrap around the screen
+	x := int(v.registers[in.x]) % width
+	y := int(v.registers[in.y]) % height
+	spriteWidth := 8
+	spriteHeight := int(in.n)
+	if spriteHeight == 0 {
+		// Height of zero draws a 16x16 sprite
+		spriteWidth = 16
+		spriteHeight = 16
+	}
 
 	v.registers[0xF] = 0
 
-	for yLine := uint16(0); yLine < height; yLine++ {
-		pixel = v.memory[v.indexRegister+yLine]
-		for xLine := uint16(0); xLine < 8; xLine++ {
-			if pixel&(0x80>>xLine) == 0 {
-				continue
-			}
-
-			if v.graphics[(x+xLine+((y+yLine)*64))] == 1 {
-				v.memory[0xF] = 1
-				continue
-			}
+	if v.mega.enabled {
+		// MegaChip sprites use the sprite size set by 03NN and 04NN
+		v.drawMegaSprite(x, y)
+		v.needsDraw = true
+		v.programCounter += 2
+		return
+	}
 
-			v.graphics[x+xLine+((y+yLine)*64)] ^= 1
+	// Each selected plane is drawn with its own sprite data, stored one after another starting at I (XO-CHIP)
+	address := int(v.indexRegister)
+	spriteSize := spriteHeight * spriteWidth / 8
+	for plane := byte(1); plane <= allPlanes; plane <<= 1 {
+		if v.planes&plane == 0 {
+			// Plane is not selected, nothing to draw
+			continue
 		}
+
+		v.drawSprite(address, x, y, spriteWidth, spriteHeight, plane)
+		address += spriteSize
 	}
 
 	// Set needs draw flag to true
 	v.needsDraw = true
 
+	if v.quirks.DisplayWait {
+		// Quirk is set, halt until the next frame
+		v.waitForFrame = true
+	}
+
 	// Increment program counter by two
 	v.programCounter += 2
 	return
 }
 
 // Skips the next instruction if the key stored in VX is pressed. (Usually the next instruction is a jump to skip a code block)
-func (v *VM) opEX9E(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "EX9E")
+func (v *VM) opEX9E(in instruction) (err error) {
+	if v.keypad[v.registers[in.x]&0x0F] != 0 {
+		// Key is pressed, skip next instruction
+		v.skipNextInstruction()
+	}
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Skips the next instruction if the key stored in VX isn't pressed. (Usually the next instruction is a jump to skip a code block)
-func (v *VM) opEXA1(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "EXA1")
+func (v *VM) opEXA1(in instruction) (err error) {
+	if v.keypad[v.registers[in.x]&0x0F] == 0 {
+		// Key is not pressed, skip next instruction
+		v.skipNextInstruction()
+	}
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
+}
+
+// Sets I to the 16 bit address stored in the next two bytes, the instruction is four bytes long. (XO-CHIP)
+func (v *VM) opF000(in instruction) (err error) {
+	v.indexRegister = uint32(v.memory.get(int(v.programCounter)+2))<<8 | uint32(v.memory.get(int(v.programCounter)+3))
+
+	// Increment program counter by 4
+	v.programCounter += 4
+	return
+}
+
+// Selects the bit planes N used by drawing, clearing and scrolling. (XO-CHIP)
+func (v *VM) opFN01(in instruction) (err error) {
+	v.planes = in.x & allPlanes
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
+}
+
+// Loads 16 bytes starting at I into the audio pattern buffer. (XO-CHIP)
+func (v *VM) opF002(in instruction) (err error) {
+	for i := range v.audioPattern {
+		v.audioPattern[i] = v.memory.get(int(v.indexRegister) + i)
+	}
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
+}
+
+// Sets the audio pattern playback pitch to VX. (XO-CHIP)
+func (v *VM) opFX3A(in instruction) (err error) {
+	v.pitch = v.registers[in.x]
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Sets VX to the value of the delay timer.
-func (v *VM) opFX07(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "FX07")
+func (v *VM) opFX07(in instruction) (err error) {
+	// Set VX to the delay timer
+	v.registers[in.x] = v.delayTimer
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // A key press is awaited, and then stored in VX. (Blocking Operation. All instruction halted until next key event)
-func (v *VM) opFX0A(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "FX0A")
+// Like the original COSMAC VIP, the key is only stored once it has been pressed and released.
+// The program counter is not incremented while waiting, so this instruction is executed again on the next cycle.
+func (v *VM) opFX0A(in instruction) (err error) {
+	if !v.waitKeyPressed {
+		for i, state := range v.keypad {
+			if state == 0 {
+				continue
+			}
+
+			// Key is pressed, wait for it to be released
+			v.waitKeyPressed = true
+			v.waitKey = byte(i)
+			break
+		}
+
+		return
+	}
+
+	if v.keypad[v.waitKey] != 0 {
+		// Key is still held down, return
+		return
+	}
+
+	// Key has been released, store it in VX
+	v.registers[in.x] = v.waitKey
+	v.waitKeyPressed = false
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Sets the delay timer to VX.
-func (v *VM) opFX15(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "FX15")
+func (v *VM) opFX15(in instruction) (err error) {
+	// Set the delay timer to VX
+	v.delayTimer = v.registers[in.x]
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Sets the sound timer to VX.
-func (v *VM) opFX18(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "FX18")
+func (v *VM) opFX18(in instruction) (err error) {
+	// Set the sound timer to VX
+	v.soundTimer = v.registers[in.x]
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Adds VX to I. VF is not affected.[c]
-func (v *VM) opFX1E(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "FX1E")
+func (v *VM) opFX1E(in instruction) (err error) {
+	// Add VX to I
+	v.indexRegister += uint32(v.registers[in.x])
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
+}
+
+// Sets I to the location of the big sprite for the character in VX. Characters 0-F (in hexadecimal) are represented by an 8x10 font. (SUPER-CHIP)
+func (v *VM) opFX30(in instruction) (err error) {
+	// Each character is 10 bytes long, only the lowest nibble of VX is used
+	v.indexRegister = bigFontsetOffset + uint32(v.registers[in.x]&0x0F)*10
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Sets I to the location of the sprite for the character in VX. Characters 0-F (in hexadecimal) are represented by a 4x5 font.
-func (v *VM) opFX29(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "FX29")
+func (v *VM) opFX29(in instruction) (err error) {
+	// Each character is 5 bytes long, only the lowest nibble of VX is used
+	v.indexRegister = fontsetOffset + uint32(v.registers[in.x]&0x0F)*5
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 //  Stores the binary-coded decimal representation of VX, with the most significant of three digits at the address in I, the middle digit at I plus 1, and the least significant digit at I plus 2. (In other words, take the decimal representation of VX, place the hundreds digit in memory at location in I, the tens digit at location I+1, and the ones digit at location I+2.)
-func (v *VM) opFX33(o opcode) (err error) {
+func (v *VM) opFX33(in instruction) (err error) {
 	// Solution credit to TJA (http://www.multigesture.net/wp-content/uploads/mirror/goldroad/chip8.shtml)
-	v.memory[v.indexRegister] = v.registers[(o&0x0F00)>>8] / 100
-	v.memory[v.indexRegister+1] = (v.registers[(o&0x0F00)>>8] / 10) % 10
-	v.memory[v.indexRegister+2] = (v.registers[(o&0x0F00)>>8] % 100) % 10
+	v.store(int(v.indexRegister), v.registers[in.x]/100)
+	v.store(int(v.indexRegister)+1, (v.registers[in.x]/10)%10)
+	v.store(int(v.indexRegister)+2, (v.registers[in.x]%100)%10)
 
 	// Increment program counter by 2
 	v.programCounter += 2
@@ -558,25 +1019,245 @@ func (v *VM) opFX33(o opcode) (err error) {
 }
 
 // Stores V0 to VX (including VX) in memory starting at address I. The offset from I is increased by 1 for each value written, but I itself is left unmodified.[d]
-func (v *VM) opFX55(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "FX55")
+// I is incremented according to the MemoryIncrement quirk.
+func (v *VM) opFX55(in instruction) (err error) {
+	x := uint16(in.x)
+	// Copy V0 through VX to memory starting at I
+	for i := 0; i <= int(x); i++ {
+		v.store(int(v.indexRegister)+i, v.registers[i])
+	}
+
+	v.incrementIndex(x)
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
 }
 
 // Fills V0 to VX (including VX) with values from memory starting at address I. The offset from I is increased by 1 for each value written, but I itself is left unmodified.[d]
-func (v *VM) opFX65(o opcode) (err error) {
-	return fmt.Errorf(errOpcodeNotImplementedFmt, "FX65")
+// I is incremented according to the MemoryIncrement quirk.
+func (v *VM) opFX65(in instruction) (err error) {
+	x := uint16(in.x)
+	// Copy memory starting at I to V0 through VX
+	for i := 0; i <= int(x); i++ {
+		v.registers[i] = v.memory.get(int(v.indexRegister) + i)
+	}
+
+	v.incrementIndex(x)
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
+}
+
+// resetFlag will set VF to 0 when the ResetVF quirk is set
+func (v *VM) resetFlag() {
+	if v.quirks.ResetVF {
+		v.registers[0xF] = 0
+	}
+}
+
+// getShiftSource will return the register value shifted by 8XY6 and 8XYE
+func (v *VM) getShiftSource(in instruction) byte {
+	if v.quirks.ShiftUsesVY {
+		return v.registers[in.y]
+	}
+
+	return v.registers[in.x]
+}
+
+// incrementIndex will increment I after FX55 and FX65 according to the MemoryIncrement quirk
+func (v *VM) incrementIndex(x uint16) {
+	switch v.quirks.MemoryIncrement {
+	case MemoryIncrementX:
+		v.indexRegister += uint32(x)
+	case MemoryIncrementXPlusOne:
+		v.indexRegister += uint32(x) + 1
+	}
+}
+
+// drawSprite will XOR a sprite onto a single plane, VF is set when a pixel of that plane is unset
+func (v *VM) drawSprite(address, x, y, spriteWidth, spriteHeight int, plane byte) {
+	var spriteRow uint16
+	width := v.graphics.Width()
+	height := v.graphics.Height()
+	for yLine := 0; yLine < spriteHeight; yLine++ {
+		py, ok := v.spriteCoordinate(y+yLine, height)
+		if !ok {
+			// Row is off screen and sprites are clipped, no more rows to draw
+			break
+		}
+
+		spriteRow = v.getSpriteRow(address, yLine, spriteWidth)
+		for xLine := 0; xLine < spriteWidth; xLine++ {
+			if spriteRow&(1<<uint(spriteWidth-1-xLine)) == 0 {
+				// Sprite pixel is not set, nothing to draw
+				continue
+			}
+
+			px, ok := v.spriteCoordinate(x+xLine, width)
+			if !ok {
+				// Column is off screen and sprites are clipped, no more columns to draw
+				break
+			}
+
+			i := px + (py * width)
+			if v.graphics.pixels[i]&plane != 0 {
+				// Pixel is being flipped from set to unset, set collision flag
+				v.registers[0xF] = 1
+			}
+
+			v.graphics.pixels[i] ^= plane
+		}
+	}
+}
+
+// getSpriteRow will return the bits of a sprite row starting at the provided address, 16 pixel wide sprites use two bytes per row
+func (v *VM) getSpriteRow(address, row, spriteWidth int) uint16 {
+	if spriteWidth == 8 {
+		return uint16(v.memory.get(address + row))
+	}
+
+	address += row * 2
+	return uint16(v.memory.get(address))<<8 | uint16(v.memory.get(address+1))
+}
+
+// skipNextInstruction will increment the program counter past the next instruction
+// The XO-CHIP F000 NNNN instruction is four bytes long and is skipped entirely
+func (v *VM) skipNextInstruction() {
+	if v.memory.get(int(v.programCounter)+2) == 0xF0 && v.memory.get(int(v.programCounter)+3) == 0x00 {
+		v.programCounter += 4
+		return
+	}
+
+	v.programCounter += 2
+}
+
+// forEachRegisterInRange will call fn for each register from X to Y (including Y) with the offset of that register from X
+func (v *VM) forEachRegisterInRange(in instruction, fn func(register, offset int)) {
+	x := int(in.x)
+	y := int(in.y)
+	step := 1
+	if x > y {
+		// Range is reversed
+		step = -1
+	}
+
+	for offset := 0; ; offset++ {
+		register := x + offset*step
+		fn(register, offset)
+		if register == y {
+			return
+		}
+	}
+}
+
+// getProgramStart will return the address programs are loaded to and started from
+func (v *VM) getProgramStart() uint16 {
+	if v.quirks.ProgramStart == 0 {
+		return 0x200
+	}
+
+	return v.quirks.ProgramStart
+}
+
+// resetGraphics will set the Graphics to a blank screen at the starting resolution
+func (v *VM) resetGraphics() {
+	// The screen has no colors, MegaChip mode ends
+	v.leaveMegaChip()
+	if v.twoPage {
+		// Two-page CHIP-8 HIRES uses a 64x64 screen
+		v.graphics = newGraphics(graphicsWidth, twoPageGraphicsHeight)
+		return
+	}
+
+	v.graphics = newGraphics(graphicsWidth, graphicsHeight)
+}
+
+// allocateMemory will ensure the memory matches the MemorySize of the current quirks
+func (v *VM) allocateMemory() {
+	size := v.quirks.MemorySize
+	if size == 0 {
+		size = DefaultMemorySize
+	}
+
+	v.memory = v.memory.resize(size)
+	v.resetDecoded()
+}
+
+// spriteCoordinate will return the on-screen position of a sprite coordinate
+// Coordinates past the edge are wrapped when the WrapSprites quirk is set, otherwise they are clipped
+func (v *VM) spriteCoordinate(coordinate, size int) (position int, ok bool) {
+	if coordinate < size {
+		return coordinate, true
+	}
+
+	if !v.quirks.WrapSprites {
+		return
+	}
+
+	return coordinate % size, true
+}
+
+func (v *VM) getStackDepth() uint16 {
+	if v.stackDepth == 0 {
+		return DefaultStackDepth
+	}
+
+	return v.stackDepth
+}
+
+func (v *VM) newStackError(err error, o opcode) *StackError {
+	var e StackError
+	e.Err = err
+	e.ProgramCounter = v.programCounter
+	e.Opcode = uint16(o)
+	return &e
+}
+
+func (v *VM) newOpcodeError(err error, o opcode) *OpcodeError {
+	var e OpcodeError
+	e.Err = err
+	e.ProgramCounter = v.programCounter
+	e.Opcode = uint16(o)
+	return &e
+}
+
+// Stores V0 to VX (including VX) in the RPL user flags. (SUPER-CHIP)
+func (v *VM) opFX75(in instruction) (err error) {
+	x := uint16(in.x)
+	copy(v.rplFlags[:x+1], v.registers[:x+1])
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
+}
+
+// Fills V0 to VX (including VX) with values from the RPL user flags. (SUPER-CHIP)
+func (v *VM) opFX85(in instruction) (err error) {
+	x := uint16(in.x)
+	copy(v.registers[:x+1], v.rplFlags[:x+1])
+
+	// Increment program counter by 2
+	v.programCounter += 2
+	return
+}
+
+func (v *VM) getInstructionsPerFrame() int {
+	if v.instructionsPerFrame == 0 {
+		// Instructions per frame have not been set, return default
+		return DefaultInstructionsPerFrame
+	}
+
+	return v.instructionsPerFrame
 }
 
 func (v *VM) updateTimers() {
 	if v.delayTimer > 0 {
-		if v.delayTimer--; v.delayTimer == 0 {
-			fmt.Println("DELAY TIMER DONE")
-		}
+		v.delayTimer--
 	}
 
 	if v.soundTimer > 0 {
-		if v.soundTimer--; v.soundTimer == 0 {
-			fmt.Println("BUZZ")
-		}
+		v.soundTimer--
 	}
 }
@@ -1,21 +1,1570 @@
 package vm
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 )
 
-func TestVM_op8XY4(t *testing.T) {
-	var (
-		vm VM
+func TestVM_op8XYN(t *testing.T) {
+	type testcase struct {
 		o  opcode
-	)
+		vx byte
+		vy byte
 
-	o = opcode(0)<<4 | opcode(7)
-	o = opcode(0)<<8 | opcode(13)
+		expectedVX byte
+		expectedVF byte
+	}
 
-	fmt.Println("O", o)
-	fmt.Println("1", uint16(o)&0x0F00)
-	fmt.Println("2", uint16(o)&0x00F0)
-	fmt.Println(vm.op8XY4(0x07E0))
+	tcs := []testcase{
+		{o: 0x8120, vx: 0x12, vy: 0x34, expectedVX: 0x34},
+		{o: 0x8121, vx: 0x0F, vy: 0xF0, expectedVX: 0xFF},
+		{o: 0x8122, vx: 0x3C, vy: 0x0F, expectedVX: 0x0C},
+		{o: 0x8123, vx: 0xFF, vy: 0x0F, expectedVX: 0xF0},
+		{o: 0x8124, vx: 0x10, vy: 0x20, expectedVX: 0x30, expectedVF: 0},
+		{o: 0x8124, vx: 0xFF, vy: 0x02, expectedVX: 0x01, expectedVF: 1},
+		{o: 0x8125, vx: 0x30, vy: 0x10, expectedVX: 0x20, expectedVF: 1},
+		{o: 0x8125, vx: 0x10, vy: 0x30, expectedVX: 0xE0, expectedVF: 0},
+		{o: 0x8126, vx: 0x05, expectedVX: 0x02, expectedVF: 1},
+		{o: 0x8127, vx: 0x10, vy: 0x30, expectedVX: 0x20, expectedVF: 1},
+		{o: 0x8127, vx: 0x30, vy: 0x10, expectedVX: 0xE0, expectedVF: 0},
+		{o: 0x812E, vx: 0x81, expectedVX: 0x02, expectedVF: 1},
+	}
+
+	for _, tc := range tcs {
+		vm := newTestVM()
+		vm.registers[1] = tc.vx
+		vm.registers[2] = tc.vy
+		if err := vm.executeOpcode(tc.o); err != nil {
+			t.Fatalf("%04X: unexpected error: %v", uint16(tc.o), err)
+		}
+
+		if vm.registers[1] != tc.expectedVX {
+			t.Fatalf("%04X: invalid VX, expected 0x%02X and received 0x%02X", uint16(tc.o), tc.expectedVX, vm.registers[1])
+		}
+
+		if vm.registers[0xF] != tc.expectedVF {
+			t.Fatalf("%04X: invalid VF, expected %d and received %d", uint16(tc.o), tc.expectedVF, vm.registers[0xF])
+		}
+
+		if vm.programCounter != 2 {
+			t.Fatalf("%04X: invalid program counter, expected 2 and received %d", uint16(tc.o), vm.programCounter)
+		}
+	}
+}
+
+func TestVM_op8XYN_flagRegister(t *testing.T) {
+	vm := newTestVM()
+	// VF + V1 with a carry, the flag must overwrite the result
+	vm.registers[0xF] = 0xFF
+	vm.registers[1] = 0x02
+	if err := vm.executeOpcode(0x8F14); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.registers[0xF] != 1 {
+		t.Fatalf("invalid VF, expected 1 and received %d", vm.registers[0xF])
+	}
+}
+
+func TestVM_subroutines(t *testing.T) {
+	vm := newTestVM()
+	vm.programCounter = 0x200
+	if err := vm.executeOpcode(0x2300); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.programCounter != 0x300 {
+		t.Fatalf("invalid program counter, expected 0x300 and received 0x%03X", vm.programCounter)
+	}
+
+	if err := vm.executeOpcode(0x00EE); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.programCounter != 0x202 {
+		t.Fatalf("invalid program counter, expected 0x202 and received 0x%03X", vm.programCounter)
+	}
+
+	var serr *StackError
+	err := vm.executeOpcode(0x00EE)
+	if !errors.As(err, &serr) || !errors.Is(err, ErrStackUnderflow) {
+		t.Fatalf("invalid error, expected a stack underflow and received %v", err)
+	}
+
+	if serr.ProgramCounter != 0x202 || serr.Opcode != 0x00EE {
+		t.Fatalf("invalid error context, received PC 0x%03X and opcode %04X", serr.ProgramCounter, serr.Opcode)
+	}
+}
+
+func TestVM_stackOverflow(t *testing.T) {
+	vm := newTestVM()
+	if err := vm.SetStackDepth(VIPStackDepth); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < VIPStackDepth; i++ {
+		if err := vm.executeOpcode(0x2200); err != nil {
+			t.Fatalf("unexpected error on call #%d: %v", i, err)
+		}
+	}
+
+	if err := vm.executeOpcode(0x2200); !errors.Is(err, ErrStackOverflow) {
+		t.Fatalf("invalid error, expected %v and received %v", ErrStackOverflow, err)
+	}
+
+	if err := vm.SetStackDepth(17); err != ErrInvalidStackDepth {
+		t.Fatalf("invalid error, expected %v and received %v", ErrInvalidStackDepth, err)
+	}
+}
+
+func TestVM_opFX(t *testing.T) {
+	vm := newTestVM()
+
+	// Timers
+	vm.registers[3] = 42
+	if err := vm.executeOpcode(0xF315); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vm.executeOpcode(0xF318); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vm.executeOpcode(0xF407); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.delayTimer != 42 || vm.soundTimer != 42 || vm.registers[4] != 42 {
+		t.Fatalf("invalid timer state, received delay %d, sound %d and V4 %d", vm.delayTimer, vm.soundTimer, vm.registers[4])
+	}
+
+	// Font lookup
+	vm.registers[5] = 0xA
+	if err := vm.executeOpcode(0xF529); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.indexRegister != fontsetOffset+50 || vm.memory[vm.indexRegister] != 0xF0 {
+		t.Fatalf("invalid font address, received 0x%03X", vm.indexRegister)
+	}
+
+	// Register store and load
+	vm.indexRegister = 0x300
+	for i := range vm.registers {
+		vm.registers[i] = byte(i + 1)
+	}
+
+	if err := vm.executeOpcode(0xF255); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.memory[0x300] != 1 || vm.memory[0x302] != 3 || vm.memory[0x303] != 0 {
+		t.Fatalf("invalid memory, received % X", vm.memory[0x300:0x304])
+	}
+
+	vm.registers = [16]byte{}
+	if err := vm.executeOpcode(0xF165); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.registers[0] != 1 || vm.registers[1] != 2 || vm.registers[2] != 0 {
+		t.Fatalf("invalid registers, received % X", vm.registers[:3])
+	}
+
+	if vm.indexRegister != 0x300 {
+		t.Fatalf("invalid index register, expected 0x300 and received 0x%03X", vm.indexRegister)
+	}
+
+	// I += VX
+	vm.registers[0] = 0x10
+	if err := vm.executeOpcode(0xF01E); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.indexRegister != 0x310 {
+		t.Fatalf("invalid index register, expected 0x310 and received 0x%03X", vm.indexRegister)
+	}
+}
+
+func TestVM_keypad(t *testing.T) {
+	vm := newTestVM()
+	vm.registers[1] = 0xA
+	vm.keypad.Set(0xA, true)
+	if err := vm.executeOpcode(0xE19E); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.programCounter != 4 {
+		t.Fatalf("invalid program counter, expected 4 and received %d", vm.programCounter)
+	}
+
+	if err := vm.executeOpcode(0xE1A1); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.programCounter != 6 {
+		t.Fatalf("invalid program counter, expected 6 and received %d", vm.programCounter)
+	}
+}
+
+func TestVM_opFX0A(t *testing.T) {
+	vm := newTestVM()
+	steps := []struct {
+		key     int
+		pressed bool
+
+		expectedPC uint16
+	}{
+		{key: 0x3, pressed: false, expectedPC: 0},
+		{key: 0x3, pressed: true, expectedPC: 0},
+		{key: 0x3, pressed: true, expectedPC: 0},
+		{key: 0x3, pressed: false, expectedPC: 2},
+	}
+
+	for i, step := range steps {
+		vm.keypad.Set(step.key, step.pressed)
+		if err := vm.executeOpcode(0xF50A); err != nil {
+			t.Fatal(err)
+		}
+
+		if vm.programCounter != step.expectedPC {
+			t.Fatalf("step %d: invalid program counter, expected %d and received %d", i, step.expectedPC, vm.programCounter)
+		}
+	}
+
+	if vm.registers[5] != 0x3 {
+		t.Fatalf("invalid V5, expected 3 and received %d", vm.registers[5])
+	}
+}
+
+func TestVM_opCXNN(t *testing.T) {
+	var a, b VM
+	a.SetRandom(NewRandom(1337))
+	b.SetRandom(NewRandom(1337))
+
+	for i := 0; i < 32; i++ {
+		if err := a.executeOpcode(0xC10F); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := b.executeOpcode(0xC10F); err != nil {
+			t.Fatal(err)
+		}
+
+		if a.registers[1] != b.registers[1] {
+			t.Fatalf("iteration %d: seeded sources diverged, received 0x%02X and 0x%02X", i, a.registers[1], b.registers[1])
+		}
+
+		if a.registers[1]&0xF0 != 0 {
+			t.Fatalf("iteration %d: value 0x%02X was not masked by NN", i, a.registers[1])
+		}
+	}
+}
+
+func TestVM_skips(t *testing.T) {
+	type testcase struct {
+		o  opcode
+		v1 byte
+		v2 byte
+
+		expectedPC uint16
+	}
+
+	tcs := []testcase{
+		{o: 0x3112, v1: 0x12, expectedPC: 4},
+		{o: 0x3112, v1: 0x13, expectedPC: 2},
+		{o: 0x4112, v1: 0x12, expectedPC: 2},
+		{o: 0x4112, v1: 0x13, expectedPC: 4},
+		{o: 0x5120, v1: 0x12, v2: 0x12, expectedPC: 4},
+		{o: 0x5120, v1: 0x12, v2: 0x13, expectedPC: 2},
+		{o: 0x9120, v1: 0x12, v2: 0x12, expectedPC: 2},
+		{o: 0x9120, v1: 0x12, v2: 0x13, expectedPC: 4},
+	}
+
+	for _, tc := range tcs {
+		vm := newTestVM()
+		vm.registers[1] = tc.v1
+		vm.registers[2] = tc.v2
+		if err := vm.executeOpcode(tc.o); err != nil {
+			t.Fatalf("%04X: unexpected error: %v", uint16(tc.o), err)
+		}
+
+		if vm.programCounter != tc.expectedPC {
+			t.Fatalf("%04X: invalid program counter, expected %d and received %d", uint16(tc.o), tc.expectedPC, vm.programCounter)
+		}
+	}
+}
+
+func TestVM_opBNNN(t *testing.T) {
+	vm := newTestVM()
+	vm.registers[0] = 0x10
+	vm.registers[3] = 0x20
+	if err := vm.executeOpcode(0xB300); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.programCounter != 0x310 {
+		t.Fatalf("invalid program counter, expected 0x310 and received 0x%03X", vm.programCounter)
+	}
+
+	vm.SetQuirks(Quirks{JumpWithVX: true})
+	if err := vm.executeOpcode(0xB300); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.programCounter != 0x320 {
+		t.Fatalf("invalid program counter, expected 0x320 and received 0x%03X", vm.programCounter)
+	}
+}
+
+func TestVM_opDXYN(t *testing.T) {
+	vm := newTestVM()
+	vm.indexRegister = 0x300
+	vm.memory[0x300] = 0xFF
+	vm.memory[0x301] = 0x81
+
+	// Draw at (60, 31), the sprite crosses both the right and the bottom edge
+	vm.registers[0] = 60
+	vm.registers[1] = 31
+	if err := vm.executeOpcode(0xD012); err != nil {
+		t.Fatal(err)
+	}
+
+	for x := 60; x < 64; x++ {
+		if vm.graphics.pixels[31*64+x] != 1 {
+			t.Fatalf("pixel (%d, 31) was not set", x)
+		}
+	}
+
+	if vm.graphics.pixels[0] != 0 || vm.graphics.pixels[31*64] != 0 {
+		t.Fatal("clipped pixels were wrapped")
+	}
+
+	if vm.registers[0xF] != 0 {
+		t.Fatalf("invalid VF, expected 0 and received %d", vm.registers[0xF])
+	}
+
+	// Draw again, every pixel is turned off and a collision is reported
+	if err := vm.executeOpcode(0xD012); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, val := range vm.graphics.pixels {
+		if val != 0 {
+			t.Fatalf("pixel %d was not unset", i)
+		}
+	}
+
+	if vm.registers[0xF] != 1 {
+		t.Fatalf("invalid VF, expected 1 and received %d", vm.registers[0xF])
+	}
+
+	// Starting coordinates wrap, and the sprite body wraps with the quirk set
+	vm.SetQuirks(Quirks{WrapSprites: true})
+	vm.registers[0] = 64 + 60
+	vm.registers[1] = 32 + 31
+	if err := vm.executeOpcode(0xD012); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.graphics.pixels[31*64+60] != 1 || vm.graphics.pixels[31*64] != 1 || vm.graphics.pixels[60] != 1 || vm.graphics.pixels[3] != 1 || vm.graphics.pixels[59] != 0 {
+		t.Fatal("sprite was not wrapped")
+	}
+}
+
+func TestVM_quirks(t *testing.T) {
+	type testcase struct {
+		name   string
+		quirks Quirks
+
+		expectedShift byte
+		expectedVF    byte
+		expectedIndex uint32
+	}
+
+	tcs := []testcase{
+		{name: "vip", quirks: VIPQuirks, expectedShift: 0x02, expectedVF: 0, expectedIndex: 0x303},
+		{name: "chip48", quirks: CHIP48Quirks, expectedShift: 0x08, expectedVF: 0xFF, expectedIndex: 0x302},
+		{name: "schip", quirks: SuperChipQuirks, expectedShift: 0x08, expectedVF: 0xFF, expectedIndex: 0x300},
+	}
+
+	for _, tc := range tcs {
+		vm := newTestVM()
+		vm.SetQuirks(tc.quirks)
+
+		// Shift
+		vm.registers[1] = 0x10
+		vm.registers[2] = 0x04
+		if err := vm.executeOpcode(0x8126); err != nil {
+			t.Fatal(err)
+		}
+
+		if vm.registers[1] != tc.expectedShift {
+			t.Fatalf("%s: invalid shift result, expected 0x%02X and received 0x%02X", tc.name, tc.expectedShift, vm.registers[1])
+		}
+
+		// VF reset
+		vm.registers[0xF] = 0xFF
+		if err := vm.executeOpcode(0x8121); err != nil {
+			t.Fatal(err)
+		}
+
+		if vm.registers[0xF] != tc.expectedVF {
+			t.Fatalf("%s: invalid VF, expected 0x%02X and received 0x%02X", tc.name, tc.expectedVF, vm.registers[0xF])
+		}
+
+		// Index increment
+		vm.indexRegister = 0x300
+		if err := vm.executeOpcode(0xF255); err != nil {
+			t.Fatal(err)
+		}
+
+		if vm.indexRegister != tc.expectedIndex {
+			t.Fatalf("%s: invalid index register, expected 0x%03X and received 0x%03X", tc.name, tc.expectedIndex, vm.indexRegister)
+		}
+
+		q, err := GetQuirks(tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if q != tc.quirks {
+			t.Fatalf("%s: invalid quirks returned by name", tc.name)
+		}
+	}
+
+	if _, err := GetQuirks("unknown"); err != ErrUnknownQuirks {
+		t.Fatalf("invalid error, expected %v and received %v", ErrUnknownQuirks, err)
+	}
+}
+
+func TestVM_displayWait(t *testing.T) {
+	vm := newTestVM()
+	vm.SetQuirks(VIPQuirks)
+	vm.programCounter = 0x200
+	// Two consecutive draws
+	copy(vm.memory[0x200:], []byte{0xD0, 0x01, 0xD0, 0x01})
+
+	if _, err := vm.Cycle(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vm.Cycle(); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.programCounter != 0x202 {
+		t.Fatalf("invalid program counter, expected 0x202 and received 0x%03X", vm.programCounter)
+	}
+
+	// Start a new frame
+	vm.waitForFrame = false
+	if _, err := vm.Cycle(); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.programCounter != 0x204 {
+		t.Fatalf("invalid program counter, expected 0x204 and received 0x%03X", vm.programCounter)
+	}
+}
+
+func TestVM_Frame(t *testing.T) {
+	vm := newTestVM()
+	vm.programCounter = 0x200
+	vm.delayTimer = 2
+	if err := vm.SetInstructionsPerFrame(3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add one to V2, then jump back to it
+	copy(vm.memory[0x200:], []byte{0x72, 0x01, 0x12, 0x00})
+
+	if _, err := vm.Frame(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add, jump, add
+	if vm.registers[2] != 2 {
+		t.Fatalf("invalid register value, expected 2 and received %d", vm.registers[2])
+	}
+
+	if vm.delayTimer != 1 {
+		t.Fatalf("invalid delay timer, expected 1 and received %d", vm.delayTimer)
+	}
+
+	if err := vm.SetInstructionsPerFrame(0); err != ErrInvalidInstructionsPerFrame {
+		t.Fatalf("invalid error, expected %v and received %v", ErrInvalidInstructionsPerFrame, err)
+	}
+}
+
+func TestVM_Frame_clear(t *testing.T) {
+	litPixels := func(g Graphics) (n int) {
+		for i := 0; i < g.Len(); i++ {
+			if g.Get(i) != 0 {
+				n++
+			}
+		}
+
+		return
+	}
+
+	type testcase struct {
+		name    string
+		opcode  []byte
+		twoPage bool
+	}
+
+	tcs := []testcase{
+		{name: "00E0", opcode: []byte{0x00, 0xE0}},
+		{name: "0230", opcode: []byte{0x02, 0x30}, twoPage: true},
+	}
+
+	for _, tc := range tcs {
+		vm := newTestVM()
+		vm.programCounter = 0x200
+		vm.twoPage = tc.twoPage
+		if err := vm.SetInstructionsPerFrame(3); err != nil {
+			t.Fatal(err)
+		}
+
+		// Draw the 0 font sprite in the first frame, clear the screen in the second and loop forever
+		copy(vm.memory[0x200:], []byte{0x60, 0x00, 0xF0, 0x29, 0xD0, 0x15})
+		copy(vm.memory[0x206:], tc.opcode)
+		copy(vm.memory[0x208:], []byte{0x12, 0x08})
+
+		needsDraw, err := vm.Frame()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := litPixels(vm.graphics); !needsDraw || n != 14 {
+			t.Fatalf("invalid first frame for %s, expected a draw of 14 pixels and received %v with %d", tc.name, needsDraw, n)
+		}
+
+		// The frame which only clears the screen needs to be drawn as well
+		if needsDraw, err = vm.Frame(); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := litPixels(vm.graphics); !needsDraw || n != 0 {
+			t.Fatalf("invalid second frame for %s, expected a draw of 0 pixels and received %v with %d", tc.name, needsDraw, n)
+		}
+	}
+}
+
+func TestVM_superChip(t *testing.T) {
+	vm := newTestVM()
+
+	// Switch to high resolution
+	if err := vm.executeOpcode(0x00FF); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.graphics.Width() != 128 || vm.graphics.Height() != 64 {
+		t.Fatalf("invalid resolution, received %dx%d", vm.graphics.Width(), vm.graphics.Height())
+	}
+
+	// Draw a 16x16 sprite at (120, 0), the right half is clipped
+	vm.indexRegister = 0x300
+	for i := 0; i < 32; i++ {
+		vm.memory[0x300+i] = 0xFF
+	}
+
+	vm.registers[0] = 120
+	vm.registers[1] = 0
+	if err := vm.executeOpcode(0xD010); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.graphics.Get(15*128+127) != 1 || vm.graphics.Get(16*128+127) != 0 || vm.graphics.Get(0) != 0 {
+		t.Fatal("invalid 16x16 sprite")
+	}
+
+	// Scroll down by 2 and left by 4
+	if err := vm.executeOpcode(0x00C2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vm.executeOpcode(0x00FC); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.graphics.Get(1*128+123) != 0 || vm.graphics.Get(2*128+123) != 1 || vm.graphics.Get(17*128+116) != 1 || vm.graphics.Get(2*128+124) != 0 {
+		t.Fatal("invalid scroll result")
+	}
+
+	// Scroll right by 4
+	if err := vm.executeOpcode(0x00FB); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.graphics.Get(2*128+127) != 1 || vm.graphics.Get(2*128+119) != 0 {
+		t.Fatal("invalid scroll result")
+	}
+
+	// Big font
+	vm.registers[2] = 0x9
+	if err := vm.executeOpcode(0xF230); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.indexRegister != bigFontsetOffset+90 || vm.memory[vm.indexRegister+2] != 0xC3 {
+		t.Fatalf("invalid big font address, received 0x%03X", vm.indexRegister)
+	}
+
+	// RPL user flags
+	vm.registers[0], vm.registers[1] = 7, 8
+	if err := vm.executeOpcode(0xF175); err != nil {
+		t.Fatal(err)
+	}
+
+	vm.registers[0], vm.registers[1] = 0, 0
+	if err := vm.executeOpcode(0xF185); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.registers[0] != 7 || vm.registers[1] != 8 {
+		t.Fatalf("invalid registers, received % X", vm.registers[:2])
+	}
+
+	// Back to low resolution and exit
+	if err := vm.executeOpcode(0x00FE); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.graphics.Width() != 64 || vm.graphics.Height() != 32 {
+		t.Fatalf("invalid resolution, received %dx%d", vm.graphics.Width(), vm.graphics.Height())
+	}
+
+	if err := vm.executeOpcode(0x00FD); err != nil {
+		t.Fatal(err)
+	}
+
+	pc := vm.programCounter
+	if _, err := vm.Cycle(); err != nil || vm.programCounter != pc {
+		t.Fatalf("program continued after exiting: %v", err)
+	}
+}
+
+func TestGraphics_ForEachDelta(t *testing.T) {
+	a := newGraphics(graphicsWidth, graphicsHeight)
+	var b Graphics
+	b.Copy(a)
+	a.pixels[5] = 1
+
+	var count int
+	a.ForEachDelta(b, func(index int, val byte) {
+		if index != 5 || val != 1 {
+			t.Fatalf("invalid delta, received %d = %d", index, val)
+		}
+
+		count++
+	})
+
+	if count != 1 {
+		t.Fatalf("invalid number of deltas, expected 1 and received %d", count)
+	}
+
+	// Every pixel changes when the resolution changes
+	count = 0
+	c := newGraphics(hiresGraphicsWidth, hiresGraphicsHeight)
+	c.ForEachDelta(a, func(index int, val byte) { count++ })
+	if count != c.Len() {
+		t.Fatalf("invalid number of deltas, expected %d and received %d", c.Len(), count)
+	}
+}
+
+func TestVM_xoChip(t *testing.T) {
+	vm := newTestVM()
+	if err := vm.SetQuirks(XOChipQuirks); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vm.memory) != XOChipMemorySize {
+		t.Fatalf("invalid memory size, expected %d and received %d", XOChipMemorySize, len(vm.memory))
+	}
+
+	// Long index load
+	vm.memory[0] = 0xF0
+	vm.memory[1] = 0x00
+	vm.memory[2] = 0xE0
+	vm.memory[3] = 0x00
+	if _, err := vm.Cycle(); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.indexRegister != 0xE000 || vm.programCounter != 4 {
+		t.Fatalf("invalid long index load, received I 0x%04X and PC %d", vm.indexRegister, vm.programCounter)
+	}
+
+	// Skipping a long index load skips all four bytes
+	vm.memory[6] = 0xF0
+	if err := vm.executeOpcode(0x3000); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.programCounter != 10 {
+		t.Fatalf("invalid program counter, expected 10 and received %d", vm.programCounter)
+	}
+
+	// Register range save and load, reversed
+	vm.registers[2], vm.registers[3], vm.registers[4] = 2, 3, 4
+	if err := vm.executeOpcode(0x5422); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.memory[0xE000] != 4 || vm.memory[0xE001] != 3 || vm.memory[0xE002] != 2 || vm.indexRegister != 0xE000 {
+		t.Fatalf("invalid memory, received % X", vm.memory[0xE000:0xE003])
+	}
+
+	if err := vm.executeOpcode(0x5793); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.registers[7] != 4 || vm.registers[8] != 3 || vm.registers[9] != 2 {
+		t.Fatalf("invalid registers, received % X", vm.registers[7:10])
+	}
+
+	// Draw on both planes, the second plane uses the sprite data after the first
+	vm.memory[0xE000] = 0x80
+	vm.memory[0xE001] = 0x40
+	if err := vm.executeOpcode(0xF301); err != nil {
+		t.Fatal(err)
+	}
+
+	vm.registers[0], vm.registers[1] = 0, 0
+	if err := vm.executeOpcode(0xD011); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.graphics.Get(0) != 1 || vm.graphics.Get(1) != 2 {
+		t.Fatalf("invalid planes, received %d and %d", vm.graphics.Get(0), vm.graphics.Get(1))
+	}
+
+	// Clearing the second plane leaves the first intact
+	if err := vm.executeOpcode(0xF201); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vm.executeOpcode(0x00E0); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.graphics.Get(0) != 1 || vm.graphics.Get(1) != 0 {
+		t.Fatalf("invalid planes, received %d and %d", vm.graphics.Get(0), vm.graphics.Get(1))
+	}
+
+	// Audio pattern and pitch
+	vm.memory[0xE00F] = 0xAA
+	if err := vm.executeOpcode(0xF002); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.AudioPattern()[15] != 0xAA {
+		t.Fatal("invalid audio pattern")
+	}
+
+	if vm.PlaybackRate() != 4000 {
+		t.Fatalf("invalid default playback rate, received %f", vm.PlaybackRate())
+	}
+
+	vm.registers[0] = 112
+	if err := vm.executeOpcode(0xF03A); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.PlaybackRate() != 8000 {
+		t.Fatalf("invalid playback rate, received %f", vm.PlaybackRate())
+	}
+}
+
+// newTestVM will return an initialized VM with the program counter at zero
+func newTestVM() (vm VM) {
+	vm.Initialize(nil)
+	vm.programCounter = 0
+	return
+}
+
+func TestVM_megaChip(t *testing.T) {
+	vm := newTestVM()
+	if err := vm.SetQuirks(MegaChipQuirks); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vm.executeOpcode(0x0011); err != nil {
+		t.Fatal(err)
+	}
+
+	if !vm.graphics.IsTrueColor() || vm.graphics.Width() != 256 || vm.graphics.Height() != 192 {
+		t.Fatalf("invalid graphics, received %dx%d", vm.graphics.Width(), vm.graphics.Height())
+	}
+
+	// Long index load of a 24 bit address
+	copy(vm.memory[vm.programCounter:], []byte{0x01, 0x12, 0x34, 0x56})
+	if err := vm.executeOpcode(0x0112); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.indexRegister != 0x123456 {
+		t.Fatalf("invalid index register, expected 0x123456 and received 0x%06X", vm.indexRegister)
+	}
+
+	// Two palette colors
+	copy(vm.memory[0x123456:], []byte{0xFF, 0xFF, 0x00, 0x00, 0xFF, 0x00, 0x00, 0xFF})
+	if err := vm.executeOpcode(0x0202); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.graphics.Palette()[1] != (color.RGBA{R: 0xFF, A: 0xFF}) || vm.graphics.Palette()[2] != (color.RGBA{B: 0xFF, A: 0xFF}) {
+		t.Fatalf("invalid palette, received %v", vm.graphics.Palette()[1:3])
+	}
+
+	// Draw a 2x1 sprite, the first pixel is transparent
+	vm.indexRegister = 0x300
+	copy(vm.memory[0x300:], []byte{0x00, 0x01})
+	for _, o := range []opcode{0x0302, 0x0401, 0x0901, 0xD010} {
+		if err := vm.executeOpcode(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if vm.graphics.Get(0) != 0 || vm.graphics.Get(1) != 1 || vm.graphics.ColorAt(1) != (color.RGBA{R: 0xFF, A: 0xFF}) {
+		t.Fatal("invalid sprite")
+	}
+
+	if vm.registers[0xF] != 0 {
+		t.Fatalf("invalid VF, expected 0 and received %d", vm.registers[0xF])
+	}
+
+	// Draw over the collision color with 50% blending
+	copy(vm.memory[0x300:], []byte{0x00, 0x02})
+	for _, o := range []opcode{0x0802, 0xD010} {
+		if err := vm.executeOpcode(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if vm.registers[0xF] != 1 {
+		t.Fatalf("invalid VF, expected 1 and received %d", vm.registers[0xF])
+	}
+
+	if c := vm.graphics.ColorAt(1); c != (color.RGBA{R: 0x7F, B: 0x7F, A: 0xFF}) {
+		t.Fatalf("invalid blended color, received %v", c)
+	}
+
+	// Digitized sound
+	vm.indexRegister = 0x400
+	copy(vm.memory[0x400:], []byte{0x1F, 0x40, 0x00, 0x00, 0x02, 0x00, 0x80, 0x81})
+	if err := vm.executeOpcode(0x0601); err != nil {
+		t.Fatal(err)
+	}
+
+	s := vm.Sample()
+	if s == nil || s.Rate != 8000 || len(s.Data) != 2 || s.Data[1] != 0x81 || s.Loop {
+		t.Fatalf("invalid sample, received %+v", s)
+	}
+
+	if err := vm.executeOpcode(0x0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.Sample() != nil {
+		t.Fatal("sample was not stopped")
+	}
+}
+
+func TestVM_megaChip_resolution(t *testing.T) {
+	type testcase struct {
+		o opcode
+
+		expectedWidth  int
+		expectedHeight int
+	}
+
+	tcs := []testcase{
+		{o: 0x00FE, expectedWidth: 64, expectedHeight: 32},
+		{o: 0x00FF, expectedWidth: 128, expectedHeight: 64},
+	}
+
+	for _, tc := range tcs {
+		vm := newTestVM()
+		if err := vm.SetQuirks(MegaChipQuirks); err != nil {
+			t.Fatal(err)
+		}
+
+		// Setting a CHIP-8 resolution leaves MegaChip mode, sprites are drawn without colors
+		vm.indexRegister = 0x300
+		vm.memory[0x300] = 0x80
+		for _, o := range []opcode{0x0011, 0x0301, 0x0401, tc.o, 0xD001} {
+			if err := vm.executeOpcode(o); err != nil {
+				t.Fatalf("error executing %04X after %04X: %v", o, tc.o, err)
+			}
+		}
+
+		if vm.graphics.IsTrueColor() || vm.graphics.Width() != tc.expectedWidth || vm.graphics.Height() != tc.expectedHeight {
+			t.Fatalf("invalid graphics for %04X, received %dx%d", tc.o, vm.graphics.Width(), vm.graphics.Height())
+		}
+
+		if vm.graphics.Get(0) != 1 {
+			t.Fatalf("invalid pixel for %04X, expected 1 and received %d", tc.o, vm.graphics.Get(0))
+		}
+
+		// Palette loads are not supported outside of MegaChip mode
+		if err := vm.op02NN(newInstruction(0x0201, (*VM).op02NN)); err == nil {
+			t.Fatalf("expected an error loading the palette after %04X", tc.o)
+		}
+	}
+}
+
+func TestVM_Load_variants(t *testing.T) {
+	dir := t.TempDir()
+	hires := filepath.Join(dir, "hires.ch8")
+	if err := ioutil.WriteFile(hires, []byte{0x12, 0x60, 0x00, 0xE0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var vm VM
+	if err := vm.Load(hires); err != nil {
+		t.Fatal(err)
+	}
+
+	vm.Initialize(nil)
+	if vm.graphics.Width() != 64 || vm.graphics.Height() != 64 {
+		t.Fatalf("invalid resolution, received %dx%d", vm.graphics.Width(), vm.graphics.Height())
+	}
+
+	// Execute the patched prologue jump
+	if _, err := vm.Cycle(); err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.programCounter != 0x2C0 {
+		t.Fatalf("invalid program counter, expected 0x2C0 and received 0x%03X", vm.programCounter)
+	}
+
+	eti := filepath.Join(dir, "eti.ch8")
+	if err := ioutil.WriteFile(eti, []byte{0x00, 0xE0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var etiVM VM
+	if err := etiVM.SetQuirks(ETI660Quirks); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := etiVM.Load(eti); err != nil {
+		t.Fatal(err)
+	}
+
+	etiVM.Initialize(nil)
+	if etiVM.programCounter != 0x600 || etiVM.memory[0x601] != 0xE0 {
+		t.Fatalf("invalid program start, received 0x%03X", etiVM.programCounter)
+	}
+
+	if etiVM.graphics.Height() != 32 {
+		t.Fatalf("invalid resolution, received %dx%d", etiVM.graphics.Width(), etiVM.graphics.Height())
+	}
+}
+
+func TestVM_Initialize(t *testing.T) {
+	rom := filepath.Join(t.TempDir(), "rom.ch8")
+	if err := ioutil.WriteFile(rom, []byte{0x00, 0xE0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var vm VM
+	if err := vm.SetQuirks(MegaChipQuirks); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vm.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+
+	vm.Initialize(nil)
+	vm.registers[3] = 0x12
+	vm.stack[0] = 0x300
+	vm.stackPointer = 1
+	vm.delayTimer = 10
+	vm.soundTimer = 20
+	vm.waitKeyPressed = true
+	vm.rplFlags[0] = 1
+	// Enable MegaChip mode with a 1x1 sprite size
+	for _, o := range []opcode{0x0011, 0x0301, 0x0401} {
+		if err := vm.executeOpcode(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Loading again leaves MegaChip mode, sprites are drawn without colors
+	if err := vm.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+
+	vm.indexRegister = 0x300
+	vm.memory[0x300] = 0x80
+	if err := vm.executeOpcode(0xD001); err != nil {
+		t.Fatal(err)
+	}
+
+	// Initializing again clears the previous run but keeps the program
+	vm.Initialize(nil)
+	if vm.registers != [16]byte{} || vm.stack != [16]uint16{} || vm.stackPointer != 0 {
+		t.Fatalf("registers and stack were not cleared, received %v %v %d", vm.registers, vm.stack, vm.stackPointer)
+	}
+
+	if vm.delayTimer != 0 || vm.soundTimer != 0 || vm.waitKeyPressed || vm.rplFlags != [16]byte{} {
+		t.Fatal("timers, key wait and RPL flags were not cleared")
+	}
+
+	if vm.mega.enabled || vm.graphics.IsTrueColor() {
+		t.Fatal("MegaChip mode was not disabled")
+	}
+
+	if vm.programCounter != 0x200 || vm.memory[0x201] != 0xE0 {
+		t.Fatalf("invalid program, received 0x%03X", vm.programCounter)
+	}
+}
+
+func TestVM_fetch_selfModifying(t *testing.T) {
+	vm := newTestVM()
+	vm.programCounter = 0x200
+	// Set V0 to 1, add 0 to V0, set I to 0x203, store V0 over the low byte of the add, then jump back to the add
+	copy(vm.memory[0x200:], []byte{0x60, 0x01, 0x70, 0x00, 0xA2, 0x03, 0xF0, 0x55, 0x12, 0x02})
+
+	// The add is decoded before it is modified and again after
+	for i := 0; i < 6; i++ {
+		if _, err := vm.Cycle(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 7001 (add 1 to V0) was written over 7000 by FX55
+	if vm.registers[0] != 2 {
+		t.Fatalf("invalid register value, expected 2 and received %d", vm.registers[0])
+	}
+}
+
+func TestVM_opcodeError(t *testing.T) {
+	vm := newTestVM()
+	vm.programCounter = 0x200
+	copy(vm.memory[0x200:], []byte{0x80, 0x0F})
+
+	_, err := vm.Cycle()
+	var oerr *OpcodeError
+	if !errors.As(err, &oerr) || !errors.Is(err, ErrInvalidOpcode) {
+		t.Fatalf("invalid error, expected %v and received %v", ErrInvalidOpcode, err)
+	}
+
+	if oerr.ProgramCounter != 0x200 || oerr.Opcode != 0x800F {
+		t.Fatalf("invalid error values, received 0x%03X and %04X", oerr.ProgramCounter, oerr.Opcode)
+	}
+
+	if err.Error() != "invalid opcode at 0x200 (opcode 800F)" {
+		t.Fatalf("invalid error message, received %s", err.Error())
+	}
+}
+
+func TestVM_SetRecompiler(t *testing.T) {
+	// Count down V0 from 10 while adding V0 to V1, storing V1 as BCD and drawing its digits
+	program := []byte{
+		0x60, 0x0A, 0x61, 0x00, 0x62, 0x00, 0x81, 0x04, 0x70, 0xFF, 0xA3, 0x00,
+		0xF1, 0x33, 0xF2, 0x65, 0xF0, 0x29, 0xD2, 0x25, 0x30, 0x00, 0x12, 0x06, 0x12, 0x18,
+	}
+
+	run := func(recompile bool) VM {
+		vm := newTestVM()
+		vm.programCounter = 0x200
+		vm.SetRecompiler(recompile)
+		copy(vm.memory[0x200:], program)
+		for i := 0; i < 20; i++ {
+			if _, err := vm.Frame(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return vm
+	}
+
+	interpreted := run(false)
+	recompiled := run(true)
+	if interpreted.registers != recompiled.registers {
+		t.Fatalf("invalid registers, expected %v and received %v", interpreted.registers, recompiled.registers)
+	}
+
+	if interpreted.programCounter != recompiled.programCounter || interpreted.indexRegister != recompiled.indexRegister {
+		t.Fatalf("invalid counters, expected 0x%03X/0x%03X and received 0x%03X/0x%03X",
+			interpreted.programCounter, interpreted.indexRegister, recompiled.programCounter, recompiled.indexRegister)
+	}
+
+	if !bytes.Equal(interpreted.graphics.pixels, recompiled.graphics.pixels) {
+		t.Fatal("invalid graphics, recompiled output does not match interpreted output")
+	}
+}
+
+func TestVM_SetRecompiler_selfModifying(t *testing.T) {
+	vm := newTestVM()
+	vm.programCounter = 0x200
+	vm.SetRecompiler(true)
+	// Set V0 to 1, add 0 to V0, set I to 0x203, store V0 over the low byte of the add, then jump back to the add
+	copy(vm.memory[0x200:], []byte{0x60, 0x01, 0x70, 0x00, 0xA2, 0x03, 0xF0, 0x55, 0x12, 0x02})
+
+	if err := vm.execute(6); err != nil {
+		t.Fatal(err)
+	}
+
+	// 7001 (add 1 to V0) was written over 7000 by FX55, the block containing it must be recompiled
+	if vm.registers[0] != 2 {
+		t.Fatalf("invalid register value, expected 2 and received %d", vm.registers[0])
+	}
+}
+
+func TestHeadlessRenderer(t *testing.T) {
+	h := NewHeadlessRenderer()
+	h.Script(KeyEvent{Frame: 1, Key: 0x5, Pressed: true}, KeyEvent{Frame: 2, Key: 0x5})
+
+	vm := newTestVM()
+	vm.r = h
+	vm.programCounter = 0x200
+	// Wait for a key in V1, point I at the sprite of its font digit, draw it and loop forever
+	copy(vm.memory[0x200:], []byte{0xF1, 0x0A, 0xF1, 0x29, 0xD0, 0x05, 0x12, 0x06})
+
+	for i := 0; i < 4; i++ {
+		if _, err := vm.Frame(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := h.Draw(vm.graphics); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := h.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		vm.SetKeys()
+	}
+
+	if h.Frames() != 4 {
+		t.Fatalf("invalid number of frames, expected 4 and received %d", h.Frames())
+	}
+
+	if vm.registers[1] != 0x5 {
+		t.Fatalf("invalid key, expected 5 and received %d", vm.registers[1])
+	}
+
+	// Top row of the 5 sprite is 0xF0
+	g := h.Graphics()
+	for x := 0; x < 8; x++ {
+		if expected := byte(0xF0>>(7-x)) & 1; g.Get(x) != expected {
+			t.Fatalf("invalid pixel at %d, expected %d and received %d", x, expected, g.Get(x))
+		}
+	}
+}
+
+func TestInputRecorder(t *testing.T) {
+	h := NewHeadlessRenderer()
+	r := NewInputRecorder(h, 42)
+
+	// Press 5 after the first frame and release it after the third
+	for frame := 1; frame <= 4; frame++ {
+		if err := r.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		h.SetKey(0x5, frame < 3)
+		r.GetKeypad()
+	}
+
+	rec := r.Recording()
+	expected := []KeyEvent{{Frame: 1, Key: 0x5, Pressed: true}, {Frame: 3, Key: 0x5}}
+	if rec.Seed != 42 || fmt.Sprint(rec.Events) != fmt.Sprint(expected) {
+		t.Fatalf("invalid recording, expected %v and received %v", expected, rec.Events)
+	}
+
+	// The text format reads back the same recording
+	var buf bytes.Buffer
+	if err := rec.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadInputRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read.Seed != rec.Seed || fmt.Sprint(read.Events) != fmt.Sprint(rec.Events) {
+		t.Fatalf("invalid recording, expected %v and received %v", rec, read)
+	}
+
+	// Events must be in frame order
+	if _, err := ReadInputRecording(bytes.NewBufferString("3 5 down\n1 5 up\n")); !errors.Is(err, ErrInvalidInputRecording) {
+		t.Fatalf("invalid error, expected %v and received %v", ErrInvalidInputRecording, err)
+	}
+}
+
+func TestVM_audio(t *testing.T) {
+	vm := newTestVM()
+	vm.programCounter = 0x200
+	// Set the sound timer to 3 frames and loop forever
+	copy(vm.memory[0x200:], []byte{0x60, 0x03, 0xF0, 0x18, 0x12, 0x04})
+
+	var a NullAudio
+	vm.SetAudio(&a)
+	for i := 0; i < 5; i++ {
+		if _, err := vm.Frame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The tone is on for exactly as many frames as the sound timer was set to
+	if a.Frames() != 5 || a.ToneFrames() != 3 || a.Tone() {
+		t.Fatalf("invalid audio, expected 5 frames with 3 tone frames and received %d frames with %d tone frames", a.Frames(), a.ToneFrames())
+	}
+}
+
+func TestWAVAudio(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "buzzer.wav")
+	a, err := CreateWAVAudio(filename, NewSquareWave(6000, 1500, 0.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tone := range []bool{true, false} {
+		if err = a.Play(tone); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 100 samples per frame
+	if len(bs) != wavHeaderSize+2*100*bytesPerSample {
+		t.Fatalf("invalid file size, received %d bytes", len(bs))
+	}
+
+	if string(bs[:4]) != "RIFF" || string(bs[8:12]) != "WAVE" || int(binary.LittleEndian.Uint32(bs[4:])) != len(bs)-8 {
+		t.Fatal("invalid RIFF header")
+	}
+
+	if size := int(binary.LittleEndian.Uint32(bs[40:])); size != 2*100*bytesPerSample {
+		t.Fatalf("invalid data size, received %d", size)
+	}
+
+	// The square wave has a period of 4 samples while the tone is on, then it is silent
+	expected := []int16{16384, 16384, -16384, -16384, 16384}
+	for i, sample := range expected {
+		if s := int16(binary.LittleEndian.Uint16(bs[wavHeaderSize+i*bytesPerSample:])); s != sample {
+			t.Fatalf("invalid sample %d, expected %d and received %d", i, sample, s)
+		}
+	}
+
+	if s := binary.LittleEndian.Uint16(bs[wavHeaderSize+100*bytesPerSample:]); s != 0 {
+		t.Fatalf("invalid sample, expected silence and received %d", int16(s))
+	}
+}
+
+func TestWAVAudio_Close(t *testing.T) {
+	var f failingFile
+	a, err := NewWAVAudio(&f, NewSquareWave(6000, 1500, 0.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file is closed even when the header cannot be written
+	a.closer = &f
+	if err = a.Close(); err != errFailingSeek {
+		t.Fatalf("invalid error, expected %v and received %v", errFailingSeek, err)
+	}
+
+	if !f.closed {
+		t.Fatal("file was not closed")
+	}
+}
+
+var errFailingSeek = errors.New("seek failed")
+
+// failingFile is a file which accepts writes but cannot seek
+type failingFile struct {
+	closed bool
+}
+
+func (f *failingFile) Write(bs []byte) (n int, err error) {
+	return len(bs), nil
+}
+
+func (f *failingFile) Seek(offset int64, whence int) (n int64, err error) {
+	return 0, errFailingSeek
+}
+
+func (f *failingFile) Close() (err error) {
+	f.closed = true
+	return
+}
+
+func TestGraphics_Image(t *testing.T) {
+	g := NewGraphics(4, 2)
+	g.Set(1, 1)
+	g.Set(6, 3)
+
+	var opts ImageOptions
+	opts.Scale = 2
+	opts.Colors[1] = color.RGBA{0, 255, 0, 255}
+
+	img := g.Image(opts)
+	if b := img.Bounds(); b.Dx() != 8 || b.Dy() != 4 {
+		t.Fatalf("invalid image size, expected 8x4 and received %dx%d", b.Dx(), b.Dy())
+	}
+
+	type testcase struct {
+		x, y     int
+		expected color.RGBA
+	}
+
+	tcs := []testcase{
+		{x: 0, y: 0, expected: color.RGBA{}},
+		{x: 2, y: 0, expected: opts.Colors[1]},
+		{x: 3, y: 1, expected: opts.Colors[1]},
+		{x: 4, y: 2, expected: color.RGBA{}},
+		{x: 5, y: 3, expected: opts.Colors[3]},
+	}
+
+	for _, tc := range tcs {
+		if c := img.RGBAAt(tc.x, tc.y); c != tc.expected {
+			t.Fatalf("invalid color at %d,%d, expected %v and received %v", tc.x, tc.y, tc.expected, c)
+		}
+	}
+
+	// Default colors are used when none are set
+	if c := g.Image(ImageOptions{}).RGBAAt(1, 0); c != DefaultColors[1] {
+		t.Fatalf("invalid color, expected %v and received %v", DefaultColors[1], c)
+	}
+
+	// True color Graphics use their own colors with the screen alpha applied
+	tg := newTrueColorGraphics(2, 1)
+	tg.colors[1] = color.RGBA{200, 100, 50, 255}
+	tg.alpha = 0x80
+	expected := color.RGBA{100, 50, 25, 128}
+	if c := tg.Image(ImageOptions{}).RGBAAt(1, 0); c != expected {
+		t.Fatalf("invalid color, expected %v and received %v", expected, c)
+	}
+}
+
+func TestGraphics_DisplayColor(t *testing.T) {
+	type testcase struct {
+		name     string
+		g        Graphics
+		index    int
+		expected color.RGBA
+	}
+
+	colors := DefaultColors
+	colors[0] = color.RGBA{}
+
+	g := NewGraphics(4, 1)
+	g.Set(1, 1)
+	g.Set(2, 2)
+	g.Set(3, 3)
+
+	tg := newTrueColorGraphics(2, 1)
+	tg.colors[1] = color.RGBA{200, 100, 50, 255}
+	tg.alpha = 0x80
+
+	tcs := []testcase{
+		{name: "unset", g: g, index: 0, expected: colors[0]},
+		{name: "first plane", g: g, index: 1, expected: colors[1]},
+		{name: "second plane", g: g, index: 2, expected: colors[2]},
+		{name: "both planes", g: g, index: 3, expected: colors[3]},
+		{name: "true color unset", g: tg, index: 0, expected: color.RGBA{}},
+		{name: "true color with screen alpha", g: tg, index: 1, expected: color.RGBA{100, 50, 25, 128}},
+	}
+
+	for _, tc := range tcs {
+		if c := tc.g.DisplayColor(tc.index, colors); c != tc.expected {
+			t.Fatalf("invalid color for %s, expected %v and received %v", tc.name, tc.expected, c)
+		}
+	}
+}
+
+func TestImageRenderer(t *testing.T) {
+	var frames []*image.RGBA
+	r := NewImageRenderer(ImageOptions{Scale: 3}, func(img *image.RGBA) {
+		frames = append(frames, img)
+	})
+
+	g := NewGraphics(graphicsWidth, graphicsHeight)
+	if err := r.Draw(g); err != nil {
+		t.Fatal(err)
+	}
+
+	g.Set(0, 1)
+	if err := r.Draw(g); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(frames) != 2 {
+		t.Fatalf("invalid number of frames, expected 2 and received %d", len(frames))
+	}
+
+	// Frames are not reused
+	if frames[0].RGBAAt(0, 0) != DefaultColors[0] || frames[1].RGBAAt(2, 2) != DefaultColors[1] {
+		t.Fatal("invalid frame contents")
+	}
+}
+
+func TestGraphics_WritePNG(t *testing.T) {
+	g := NewGraphics(graphicsWidth, graphicsHeight)
+	g.Set(graphicsWidth+1, 1)
+
+	var buf bytes.Buffer
+	if err := g.WritePNG(&buf, ImageOptions{Scale: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := img.Bounds(); b.Dx() != graphicsWidth*2 || b.Dy() != graphicsHeight*2 {
+		t.Fatalf("invalid image size, expected %dx%d and received %dx%d", graphicsWidth*2, graphicsHeight*2, b.Dx(), b.Dy())
+	}
+
+	if c := color.RGBAModel.Convert(img.At(2, 2)); c != DefaultColors[1] {
+		t.Fatalf("invalid color, expected %v and received %v", DefaultColors[1], c)
+	}
+}
+
+func TestGIFRecorder(t *testing.T) {
+	r := NewGIFRecorder(NewHeadlessRenderer(), ImageOptions{Scale: 2})
+	if err := r.Encode(ioutil.Discard); err != ErrNoRecording {
+		t.Fatalf("invalid error, expected %v and received %v", ErrNoRecording, err)
+	}
+
+	g := NewGraphics(graphicsWidth, graphicsHeight)
+	if err := r.Draw(g); err != nil {
+		t.Fatal(err)
+	}
+
+	// The drawn frame is recorded once recording starts, drawing it again extends it
+	r.Start()
+	r.Update()
+	r.Update()
+	r.Draw(g)
+	r.Update()
+
+	g.Set(0, 1)
+	r.Draw(g)
+	r.Update()
+	r.Stop()
+
+	// Frames after stopping are not recorded
+	g.Set(1, 1)
+	r.Draw(g)
+	r.Update()
+
+	var buf bytes.Buffer
+	if err := r.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(anim.Image) != 2 {
+		t.Fatalf("invalid number of frames, expected 2 and received %d", len(anim.Image))
+	}
+
+	// 3 frames are 5/100 s, 4 frames are 7/100 s
+	if anim.Delay[0] != 5 || anim.Delay[1] != 2 {
+		t.Fatalf("invalid delays, expected [5 2] and received %v", anim.Delay)
+	}
+
+	img := anim.Image[1]
+	if b := img.Bounds(); b.Dx() != graphicsWidth*2 || b.Dy() != graphicsHeight*2 {
+		t.Fatalf("invalid image size, expected %dx%d and received %dx%d", graphicsWidth*2, graphicsHeight*2, b.Dx(), b.Dy())
+	}
+
+	if len(img.Palette) != 2 {
+		t.Fatalf("invalid palette size, expected 2 and received %d", len(img.Palette))
+	}
+
+	if img.ColorIndexAt(1, 1) != 1 || img.ColorIndexAt(2, 0) != 0 {
+		t.Fatal("invalid frame contents")
+	}
+}
+
+func BenchmarkVM_Cycle(b *testing.B) {
+	vm := newTestVM()
+	vm.programCounter = 0x200
+	// Set V0, add V1 to it, subtract V2 from V1, increment V3, skip when V3 is 0, set I, add V0 to I and jump back to the start
+	copy(vm.memory[0x200:], []byte{0x60, 0x05, 0x80, 0x14, 0x81, 0x25, 0x73, 0x01, 0x33, 0x00, 0xA0, 0x50, 0xF0, 0x1E, 0x12, 0x00})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := vm.Cycle(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVM_Cycle_invalidOpcode(b *testing.B) {
+	vm := newTestVM()
+	vm.programCounter = 0x200
+	copy(vm.memory[0x200:], []byte{0x80, 0x0F})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := vm.Cycle(); err == nil {
+			b.Fatal("expected error")
+		}
+	}
+}
+
+func BenchmarkVM_Frame_interpreter(b *testing.B) {
+	benchmarkFrame(b, false)
+}
+
+func BenchmarkVM_Frame_recompiler(b *testing.B) {
+	benchmarkFrame(b, true)
+}
+
+func benchmarkFrame(b *testing.B, recompile bool) {
+	vm := newTestVM()
+	vm.programCounter = 0x200
+	vm.SetRecompiler(recompile)
+	if err := vm.SetInstructionsPerFrame(1000); err != nil {
+		b.Fatal(err)
+	}
+
+	// Set V0, add V1 to it, subtract V2 from V1, increment V3, skip when V3 is 0, set I, add V0 to I and jump back to the start
+	copy(vm.memory[0x200:], []byte{0x60, 0x05, 0x80, 0x14, 0x81, 0x25, 0x73, 0x01, 0x33, 0x00, 0xA0, 0x50, 0xF0, 0x1E, 0x12, 0x00})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := vm.Frame(); err != nil {
+			b.Fatal(err)
+		}
+	}
 }